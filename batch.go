@@ -0,0 +1,268 @@
+package simplerouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BatchOptions configures a batch handler. The zero value is usable.
+type BatchOptions struct {
+	// MaxRequests is the largest number of requests in a batch. Defaults to
+	// 20.
+	MaxRequests int
+	// Parallelism is the number of requests served at once. Requests are
+	// served one after the other, in order, when it is 0 or 1.
+	Parallelism int
+	// InheritHeaders are the headers of the batch request copied to each of
+	// its requests, unless they set their own. Defaults to Authorization,
+	// Cookie and Accept-Language.
+	InheritHeaders []string
+}
+
+// BatchRequest is one of the requests of a batch.
+type BatchRequest struct {
+	// ID names the request in responses and in DependsOn.
+	ID string `json:"id,omitempty"`
+	// Method defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Path is the path of the request, with its query string.
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as JSON.
+	Body json.RawMessage `json:"body,omitempty"`
+	// DependsOn lists the IDs of earlier requests that must succeed before
+	// this one is served. If one of them fails, this request is answered with
+	// 424 Failed Dependency without being served.
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+// BatchResponse is the response to one of the requests of a batch.
+type BatchResponse struct {
+	ID      string      `json:"id,omitempty"`
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body is embedded as is if it is JSON, and as a string otherwise.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+type batchBody struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+type batchResult struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+type batchContextKey struct{}
+
+// Batch returns a handler serving several requests in one round trip, each
+// through handler as if it had been sent on its own, typically with the router
+// the batch is registered on:
+//
+//	r.Post("/batch", Batch(r, BatchOptions{Parallelism: 4}))
+//
+// The body lists the requests and the response lists their responses, in the
+// same order:
+//
+//	{"requests": [
+//		{"id": "user", "method": "POST", "path": "/users", "body": {"name": "Ann"}},
+//		{"method": "GET", "path": "/users?limit=10", "depends_on": ["user"]}
+//	]}
+//
+//	{"responses": [
+//		{"id": "user", "status": 201, "headers": {...}, "body": {"id": 7, "name": "Ann"}},
+//		{"status": 200, "headers": {...}, "body": [...]}
+//	]}
+//
+// Requests run through the middleware of their routes, and unmatched paths
+// get the usual not found responses. Batches cannot be nested.
+func Batch(handler http.Handler, opts BatchOptions) http.HandlerFunc {
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 1
+	}
+	if opts.InheritHeaders == nil {
+		opts.InheritHeaders = []string{"Authorization", "Cookie", "Accept-Language"}
+	}
+
+	return Func(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Context().Value(batchContextKey{}) != nil {
+			return NewError(http.StatusBadRequest, "batch requests cannot be nested")
+		}
+
+		var body batchBody
+		if err := decodeJSON(w, r, &body); err != nil {
+			return err
+		}
+		switch {
+		case len(body.Requests) == 0:
+			return NewError(http.StatusBadRequest, "batch contains no requests")
+		case len(body.Requests) > opts.MaxRequests:
+			return Errorf(http.StatusBadRequest, "batches are limited to %d requests", opts.MaxRequests)
+		}
+
+		deps, err := batchDependencies(body.Requests)
+		if err != nil {
+			return err
+		}
+		reqs := make([]*http.Request, len(body.Requests))
+		for i, sub := range body.Requests {
+			if reqs[i], err = newBatchRequest(r, sub, opts.InheritHeaders); err != nil {
+				return err
+			}
+		}
+
+		responses := make([]BatchResponse, len(reqs))
+		done := make([]chan struct{}, len(reqs))
+		for i := range done {
+			done[i] = make(chan struct{})
+		}
+		serve := func(i int) {
+			defer close(done[i])
+			for _, dep := range deps[i] {
+				<-done[dep]
+				if responses[dep].Status >= http.StatusBadRequest {
+					recorder := &responseRecorder{header: http.Header{}}
+					WriteError(recorder, r, Errorf(http.StatusFailedDependency, "request %q failed", body.Requests[dep].ID))
+					responses[i] = batchResponse(body.Requests[i].ID, recorder)
+					return
+				}
+			}
+			responses[i] = batchResponse(body.Requests[i].ID, serveBatchRequest(handler, reqs[i]))
+		}
+
+		if opts.Parallelism == 1 {
+			for i := range reqs {
+				serve(i)
+			}
+		} else {
+			var wg sync.WaitGroup
+			slots := make(chan struct{}, opts.Parallelism)
+			for i := range reqs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for _, dep := range deps[i] {
+						<-done[dep]
+					}
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					serve(i)
+				}()
+			}
+			wg.Wait()
+		}
+
+		return writeJSON(w, batchResult{Responses: responses})
+	})
+}
+
+// batchDependencies resolves the DependsOn IDs of each request to the indexes
+// of earlier requests, which rules out cycles.
+func batchDependencies(reqs []BatchRequest) ([][]int, error) {
+	ids := map[string]int{}
+	deps := make([][]int, len(reqs))
+	for i, req := range reqs {
+		for _, id := range req.DependsOn {
+			dep, ok := ids[id]
+			if !ok {
+				return nil, Errorf(http.StatusBadRequest, "request %d depends on %q, which is not an earlier request", i, id)
+			}
+			deps[i] = append(deps[i], dep)
+		}
+		if req.ID == "" {
+			continue
+		}
+		if _, ok := ids[req.ID]; ok {
+			return nil, Errorf(http.StatusBadRequest, "request id %q is used twice", req.ID)
+		}
+		ids[req.ID] = i
+	}
+	return deps, nil
+}
+
+// newBatchRequest builds the request served for sub, carrying the context and
+// connection details of the batch request r.
+func newBatchRequest(r *http.Request, sub BatchRequest, inherit []string) (*http.Request, error) {
+	method := sub.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !strings.HasPrefix(sub.Path, "/") || strings.HasPrefix(sub.Path, "//") {
+		return nil, Errorf(http.StatusBadRequest, "invalid batch request path %q", sub.Path)
+	}
+
+	// The route and mount of the batch request must not leak into the
+	// requests it serves, which are matched again from the top.
+	ctx := context.WithValue(r.Context(), batchContextKey{}, true)
+	ctx = context.WithValue(ctx, routeContextKey{}, struct{}{})
+	ctx = context.WithValue(ctx, originalPathContextKey{}, struct{}{})
+
+	req, err := http.NewRequestWithContext(ctx, method, sub.Path, bytes.NewReader(sub.Body))
+	if err != nil {
+		return nil, Errorf(http.StatusBadRequest, "invalid batch request %s %s: %w", method, sub.Path, err)
+	}
+	req.RequestURI = sub.Path
+	req.Proto, req.ProtoMajor, req.ProtoMinor = r.Proto, r.ProtoMajor, r.ProtoMinor
+	req.Host = r.Host
+	req.RemoteAddr = r.RemoteAddr
+	req.TLS = r.TLS
+
+	for _, name := range inherit {
+		if values := r.Header.Values(name); len(values) > 0 {
+			req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	for name, value := range sub.Headers {
+		req.Header.Set(name, value)
+	}
+	if len(sub.Body) > 0 && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// serveBatchRequest serves req through handler, answering with 500 if it
+// panics.
+func serveBatchRequest(handler http.Handler, req *http.Request) (recorder *responseRecorder) {
+	recorder = &responseRecorder{header: http.Header{}}
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Debug("Batched request panicked", "method", req.Method, "path", req.URL.Path, "panic", p)
+			recorder = &responseRecorder{header: http.Header{}}
+			WriteError(recorder, req, fmt.Errorf("panic: %v", p))
+		}
+	}()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func batchResponse(id string, recorder *responseRecorder) BatchResponse {
+	response := BatchResponse{ID: id, Status: recorder.status, Headers: recorder.header}
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	if len(response.Headers) == 0 {
+		response.Headers = nil
+	}
+
+	body := recorder.body.Bytes()
+	if len(body) == 0 {
+		return response
+	}
+	mediaType, _, _ := mime.ParseMediaType(recorder.header.Get("Content-Type"))
+	if isJSONMediaType(mediaType) && json.Valid(body) {
+		response.Body = json.RawMessage(bytes.TrimSpace(body))
+	} else {
+		response.Body, _ = json.Marshal(string(body))
+	}
+	return response
+}
@@ -0,0 +1,189 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBatch(opts BatchOptions) (*Router, *[]string) {
+	var seen []string
+	router := NewRouter(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.Method+" "+r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"lang":%q,"q":%q}`, r.PathValue("id"), r.Header.Get("Accept-Language"), r.URL.Query().Get("q"))
+	})
+	router.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	router.Get("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	})
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	router.Post("/batch", Batch(router, opts))
+	return router, &seen
+}
+
+func postBatch(router *Router, body string, header ...string) (*httptest.ResponseRecorder, []BatchResponse) {
+	r := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var result struct{ Responses []BatchResponse }
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w, result.Responses
+}
+
+func TestBatch(t *testing.T) {
+	router, seen := newTestBatch(BatchOptions{})
+
+	w, responses := postBatch(router, `{"requests":[
+		{"id":"get","path":"/users/7?q=x"},
+		{"method":"POST","path":"/users","body":{"name":"Ann"}},
+		{"path":"/text"},
+		{"path":"/missing"},
+		{"path":"/panic"}
+	]}`, "Accept-Language", "fr", "X-Secret", "1")
+	if w.Code != http.StatusOK || len(responses) != 5 {
+		t.Fatalf("Expected five responses, got %d %s", w.Code, w.Body.String())
+	}
+
+	expected := []struct {
+		id     string
+		status int
+		body   string
+	}{
+		{"get", http.StatusOK, `{"id":"7","lang":"fr","q":"x"}`},
+		{"", http.StatusCreated, `{"name":"Ann"}`},
+		{"", http.StatusOK, `"plain"`},
+		{"", http.StatusNotFound, ""},
+		{"", http.StatusInternalServerError, ""},
+	}
+	for i, e := range expected {
+		response := responses[i]
+		if response.ID != e.id || response.Status != e.status || (e.body != "" && string(response.Body) != e.body) {
+			t.Errorf("Expected response %d to be %s %d %s, got %+v", i, e.id, e.status, e.body, response)
+		}
+	}
+	if responses[1].Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON bodies to be sent as JSON, got %v", responses[1].Headers)
+	}
+
+	// Unmatched paths skip route middleware, as they do outside of batches.
+	expectedSeen := "POST /batch,GET /users/7,POST /users,GET /text,GET /panic"
+	if strings.Join(*seen, ",") != expectedSeen {
+		t.Errorf("Expected the requests to run through the middleware, got %v", *seen)
+	}
+}
+
+func TestBatchDependencies(t *testing.T) {
+	router, seen := newTestBatch(BatchOptions{})
+
+	_, responses := postBatch(router, `{"requests":[
+		{"id":"a","path":"/users/1"},
+		{"id":"b","path":"/missing","depends_on":["a"]},
+		{"id":"c","path":"/users/2","depends_on":["b"]},
+		{"path":"/users/3","depends_on":["a"]}
+	]}`)
+	statuses := fmt.Sprint(responses[0].Status, responses[1].Status, responses[2].Status, responses[3].Status)
+	if statuses != "200 404 424 200" {
+		t.Errorf("Expected failed dependencies to skip their dependents, got %s", statuses)
+	}
+	if strings.Contains(strings.Join(*seen, ","), "/users/2") {
+		t.Error("Expected the skipped request not to be served")
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown", `{"requests":[{"path":"/users/1","depends_on":["x"]}]}`},
+		{"later", `{"requests":[{"path":"/users/1","depends_on":["b"]},{"id":"b","path":"/users/2"}]}`},
+		{"duplicate", `{"requests":[{"id":"a","path":"/users/1"},{"id":"a","path":"/users/2"}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w, _ := postBatch(router, test.body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected the batch to be rejected, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestBatchParallel(t *testing.T) {
+	var running, peak atomic.Int32
+	router := NewRouter()
+	router.Get("/slow/{n}", func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(r.PathValue("n")))
+	})
+	router.Post("/batch", Batch(router, BatchOptions{Parallelism: 2}))
+
+	_, responses := postBatch(router, `{"requests":[
+		{"id":"a","path":"/slow/1"},{"path":"/slow/2"},{"path":"/slow/3"},{"path":"/slow/4","depends_on":["a"]}
+	]}`)
+	for i, response := range responses {
+		if string(response.Body) != fmt.Sprintf(`"%d"`, i+1) {
+			t.Errorf("Expected the responses in order, got %s at %d", response.Body, i)
+		}
+	}
+	if peak.Load() != 2 {
+		t.Errorf("Expected two requests at a time, got %d", peak.Load())
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	router, _ := newTestBatch(BatchOptions{MaxRequests: 2})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty", `{"requests":[]}`},
+		{"too many", `{"requests":[{"path":"/text"},{"path":"/text"},{"path":"/text"}]}`},
+		{"relative path", `{"requests":[{"path":"text"}]}`},
+		{"absolute URL", `{"requests":[{"path":"//example.com/text"}]}`},
+		{"invalid method", `{"requests":[{"method":"GET /","path":"/text"}]}`},
+		{"invalid JSON", `{"requests":`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w, _ := postBatch(router, test.body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected the batch to be rejected, got %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w, responses := postBatch(router, `{"requests":[{"method":"POST","path":"/batch","body":{"requests":[{"path":"/text"}]}}]}`)
+	if w.Code != http.StatusOK || len(responses) != 1 || responses[0].Status != http.StatusBadRequest {
+		t.Errorf("Expected nested batches to be rejected, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,379 @@
+package simplerouter
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bind populates the struct pointed to by v from the request, using struct
+// tags to name the source of each field:
+//
+//	type ListUsers struct {
+//		Org    string   `path:"org"`
+//		Limit  int      `query:"limit" default:"20"`
+//		Tags   []string `query:"tag"`
+//		Tenant string   `header:"X-Tenant"`
+//		Token  string   `cookie:"session"`
+//		Name   string   `form:"name"`
+//	}
+//
+// Form fields are read from URL-encoded and multipart bodies. Any other body
+// is decoded as JSON into the untagged and form fields, with the rules of the
+// JSON adapter, when the struct has some; the body cannot set fields bound
+// from other sources. Fields are converted to strings, bools, numbers,
+// time.Duration, time.Time (RFC 3339), encoding.TextUnmarshaler
+// implementations, and pointers or slices of those. Absent values take the
+// default tag, or keep their zero value.
+//
+// Conversion failures of all fields are reported together as a *BindError,
+// which responds 400 with the failures in the "errors" problem member.
+func Bind(r *http.Request, v any) error {
+	return bind(nil, r, v, false)
+}
+
+// BindFunc adapts a handler taking a bound and validated struct into a handler
+// for the route methods of a Router or for Handle. Routes registered with
+// HandleBind also document T.
+func BindFunc[T any](fn func(w http.ResponseWriter, r *http.Request, in T) error) http.HandlerFunc {
+	return Func(func(w http.ResponseWriter, r *http.Request) error {
+		var in T
+		if err := bind(w, r, &in, false); err != nil {
+			return err
+		}
+		if err := Validate(&in); err != nil {
+			return err
+		}
+		return fn(w, r, in)
+	})
+}
+
+// HandleBind registers BindFunc(fn) as the handler of method and path on r,
+// running chain around it, and documents T as the request type of the route
+// in the OpenAPI document of the Router.
+func HandleBind[T any](r *Router, method, path string, fn func(w http.ResponseWriter, r *http.Request, in T) error, chain ...middleware) {
+	r.withDefaults(Accepts[T]()).handle(method, path, BindFunc(fn), chain)
+}
+
+// FieldError is a request value that could not be bound to a field.
+type FieldError struct {
+	// Source is the tag the value came from, such as "query".
+	Source string `json:"source"`
+	// Name is the name of the value in its source.
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// BindError reports every field Bind could not populate.
+type BindError struct {
+	Fields []FieldError
+}
+
+func (e *BindError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s %q: %s", field.Source, field.Name, field.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Problem returns the 400 response for the error.
+func (e *BindError) Problem() *Problem {
+	return NewProblem(http.StatusBadRequest, "The request has invalid parameters.").With("errors", e.Fields)
+}
+
+var bindSources = []string{"path", "query", "header", "cookie", "form"}
+
+type bindField struct {
+	index    []int
+	source   string
+	name     string
+	fallback string
+	hasValue bool
+}
+
+type bindPlan struct {
+	fields []bindField
+	// body is set when some fields are left to the JSON body.
+	body bool
+	// form is set when some fields are bound from form values.
+	form bool
+}
+
+var bindPlans sync.Map
+
+func planFor(t reflect.Type) (*bindPlan, error) {
+	if cached, ok := bindPlans.Load(t); ok {
+		return cached.(*bindPlan), nil
+	}
+
+	plan := &bindPlan{}
+	if err := plan.add(t, nil); err != nil {
+		return nil, err
+	}
+
+	cached, _ := bindPlans.LoadOrStore(t, plan)
+	return cached.(*bindPlan), nil
+}
+
+func (p *bindPlan) add(t reflect.Type, parent []int) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := p.add(field.Type, index); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		bound := false
+		for _, source := range bindSources {
+			name, ok := field.Tag.Lookup(source)
+			if !ok {
+				continue
+			}
+			if bound {
+				return fmt.Errorf("simplerouter: field %s has more than one binding source", field.Name)
+			}
+			if !canConvert(field.Type) {
+				return fmt.Errorf("simplerouter: cannot bind field %s of type %s", field.Name, field.Type)
+			}
+
+			fallback, hasDefault := field.Tag.Lookup("default")
+			p.fields = append(p.fields, bindField{index, source, name, fallback, hasDefault})
+			p.form = p.form || source == "form"
+			bound = true
+		}
+
+		if !bound && field.Tag.Get("json") != "-" {
+			p.body = true
+		}
+	}
+	return nil
+}
+
+// bind populates v. The JSON adapter forces body decoding, since its request
+// type may be anything JSON can decode into.
+func bind(w http.ResponseWriter, r *http.Request, v any, jsonBody bool) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("simplerouter: Bind requires a non-nil pointer, got %T", v)
+	}
+
+	target := value.Elem()
+	if target.Kind() != reflect.Struct {
+		if jsonBody {
+			return decodeJSON(w, r, v)
+		}
+		return fmt.Errorf("simplerouter: Bind requires a pointer to a struct, got %T", v)
+	}
+
+	plan, err := planFor(target.Type())
+	if err != nil {
+		return err
+	}
+
+	// The JSON adapter only reads forms into form fields; other form bodies
+	// go on to decodeJSON, which rejects them with a 415.
+	if isFormRequest(r) && (!jsonBody || plan.form) {
+		if err := parseForm(w, r); err != nil {
+			return err
+		}
+	} else if plan.body || jsonBody {
+		if err := decodeJSON(w, r, v); err != nil {
+			return err
+		}
+		// Fields bound from the path, query, headers or cookies must not be
+		// settable through the body, even when their source is absent.
+		for _, field := range plan.fields {
+			if field.source != "form" {
+				target.FieldByIndex(field.index).SetZero()
+			}
+		}
+	}
+
+	var bindErr BindError
+	for _, field := range plan.fields {
+		values := lookupValues(r, field.source, field.name)
+		if len(values) == 0 {
+			if !field.hasValue {
+				continue
+			}
+			values = []string{field.fallback}
+		}
+
+		if err := setField(target.FieldByIndex(field.index), values); err != nil {
+			bindErr.Fields = append(bindErr.Fields, FieldError{field.source, field.name, err.Error()})
+		}
+	}
+
+	if len(bindErr.Fields) > 0 {
+		return &bindErr
+	}
+	return nil
+}
+
+func isFormRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+func parseForm(w http.ResponseWriter, r *http.Request) error {
+	limit := int64(DefaultJSONBodyLimit)
+	if route, ok := RouteFromContext(r.Context()); ok && route.BodyLimit > 0 {
+		limit = route.BodyLimit
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	err := r.ParseMultipartForm(limit)
+	if errors.Is(err, http.ErrNotMultipart) {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return &Error{Status: http.StatusRequestEntityTooLarge, Err: err}
+		}
+		return Errorf(http.StatusBadRequest, "invalid form body: %w", err)
+	}
+	return nil
+}
+
+func lookupValues(r *http.Request, source, name string) []string {
+	switch source {
+	case "path":
+		if value := r.PathValue(name); value != "" {
+			return []string{value}
+		}
+	case "query":
+		return r.URL.Query()[name]
+	case "header":
+		return r.Header.Values(name)
+	case "cookie":
+		if cookie, err := r.Cookie(name); err == nil {
+			return []string{cookie.Value}
+		}
+	case "form":
+		if r.MultipartForm != nil {
+			if values := r.MultipartForm.Value[name]; len(values) > 0 {
+				return values
+			}
+		}
+		return r.PostForm[name]
+	}
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	timeType            = reflect.TypeFor[time.Time]()
+)
+
+func canConvert(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) || t == durationType || t == timeType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && canConvert(t.Elem())
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func setField(field reflect.Value, values []string) error {
+	switch {
+	case field.Kind() == reflect.Slice && !reflect.PointerTo(field.Type()).Implements(textUnmarshalerType):
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case field.Kind() == reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid RFC 3339 time %q", value)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listUsers struct {
+	Org     string        `path:"org"`
+	Limit   int           `query:"limit" default:"20"`
+	Tags    []string      `query:"tag"`
+	Active  *bool         `query:"active"`
+	Timeout time.Duration `query:"timeout" default:"5s"`
+	Tenant  string        `header:"X-Tenant"`
+	Session string        `cookie:"session"`
+}
+
+func TestBind(t *testing.T) {
+	var bound listUsers
+	router := NewRouter()
+	router.Handle("GET", "/orgs/{org}/users", BindFunc(func(w http.ResponseWriter, r *http.Request, in listUsers) error {
+		bound = in
+		return nil
+	}))
+
+	t.Run("populates every source", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/orgs/acme/users?limit=5&tag=a&tag=b&active=true", nil)
+		req.Header.Set("X-Tenant", "blue")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "s3cret"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		active := true
+		expected := listUsers{"acme", 5, []string{"a", "b"}, &active, 5 * time.Second, "blue", "s3cret"}
+		if !reflect.DeepEqual(bound, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, bound)
+		}
+	})
+
+	t.Run("applies defaults", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/orgs/acme/users", nil))
+
+		if bound.Limit != 20 || bound.Active != nil || bound.Tags != nil {
+			t.Errorf("Expected defaults and zero values, got %+v", bound)
+		}
+	})
+
+	t.Run("aggregates field errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/orgs/acme/users?limit=many&active=maybe", nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+
+		var body struct {
+			Errors []FieldError `json:"errors"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+
+		expected := []FieldError{
+			{"query", "limit", `invalid integer "many"`},
+			{"query", "active", `invalid boolean "maybe"`},
+		}
+		if !reflect.DeepEqual(body.Errors, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, body.Errors)
+		}
+	})
+}
+
+func TestBindBody(t *testing.T) {
+	type updateUser struct {
+		ID    int    `path:"id" json:"-"`
+		Name  string `form:"name" json:"name"`
+		Email string `json:"email"`
+	}
+
+	t.Run("form", func(t *testing.T) {
+		form := url.Values{"name": {"ada"}}
+		req := httptest.NewRequest("PUT", "/users/7", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", "7")
+
+		var in updateUser
+		if err := Bind(req, &in); err != nil {
+			t.Fatal(err)
+		}
+
+		if in.ID != 7 || in.Name != "ada" {
+			t.Errorf("Expected the form and path values, got %+v", in)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"email": "ada@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", "7")
+
+		var in updateUser
+		if err := Bind(req, &in); err != nil {
+			t.Fatal(err)
+		}
+
+		if in.ID != 7 || in.Email != "ada@example.com" {
+			t.Errorf("Expected the JSON and path values, got %+v", in)
+		}
+	})
+
+	t.Run("spoofed sources", func(t *testing.T) {
+		type createItem struct {
+			Tenant string `header:"X-Tenant"`
+			Token  string `cookie:"session" default:"anonymous"`
+			Name   string
+		}
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(`{"Name":"a","Tenant":"evil","Token":"stolen"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		var in createItem
+		if err := Bind(req, &in); err != nil {
+			t.Fatal(err)
+		}
+
+		if in != (createItem{Token: "anonymous", Name: "a"}) {
+			t.Errorf("Expected the body not to set header and cookie fields, got %+v", in)
+		}
+	})
+
+	t.Run("typed JSON handlers", func(t *testing.T) {
+		router := NewRouter()
+		router.Put("/users/{id}", JSON(func(ctx context.Context, in updateUser) (map[string]any, error) {
+			return map[string]any{"id": in.ID, "name": in.Name}, nil
+		}))
+
+		req := httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"name": "ada", "id": 1}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// "id" is not a member of the JSON body, so it is an unknown field.
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+
+		req = httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"name": "ada"}`))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 || w.Body.String() != `{"id":7,"name":"ada"}`+"\n" {
+			t.Errorf("Expected the bound request, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects untyped targets", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		var n int
+		if err := Bind(req, &n); err == nil {
+			t.Error("Expected an error binding into a non-struct")
+		}
+
+		var unsupported struct {
+			Callback func() `query:"cb"`
+		}
+		if err := Bind(req, &unsupported); err == nil {
+			t.Error("Expected an error binding an unsupported field type")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package simplerouter
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// BodyOptions configures the DecodeBody middleware.
+type BodyOptions struct {
+	// MaxSize limits the request body as received on the wire. Zero disables
+	// the limit.
+	MaxSize int64
+	// MaxDecodedSize limits the body once its Content-Encoding has been
+	// removed, guarding against decompression bombs. Defaults to MaxSize.
+	MaxDecodedSize int64
+}
+
+// DecodeBody returns a middleware that transparently decodes gzip and deflate
+// request bodies and enforces size limits before and after decompression.
+// Routes registered with the BodyLimit option override both limits.
+//
+// Requests whose Content-Length already exceeds the limit are rejected with a
+// 413 before the handler runs. Otherwise reads past the limit fail with an
+// *http.MaxBytesError, and the 413 is written for the handler if it returns
+// without responding. Unsupported codings are rejected with a 415.
+func DecodeBody(opts BodyOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			maxSize, maxDecodedSize := opts.MaxSize, opts.MaxDecodedSize
+			if maxDecodedSize == 0 {
+				maxDecodedSize = maxSize
+			}
+			if route, ok := RouteFromContext(r.Context()); ok && route.BodyLimit > 0 {
+				maxSize, maxDecodedSize = route.BodyLimit, route.BodyLimit
+			}
+
+			if maxSize > 0 && r.ContentLength > maxSize {
+				writeError(w, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			codings, ok := contentCodings(r.Header.Get("Content-Encoding"))
+			if !ok {
+				writeError(w, r, http.StatusUnsupportedMediaType)
+				return
+			}
+
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			exceeded := false
+			original := r.Body
+
+			var body io.Reader = original
+			if maxSize > 0 {
+				body = &limitedReader{reader: body, remaining: maxSize, limit: maxSize, exceeded: &exceeded}
+			}
+
+			for _, coding := range slices.Backward(codings) {
+				decoded, err := newDecoder(coding, body)
+				if err != nil {
+					// The header of the stream is read up front, so the wire
+					// limit can be hit before the handler runs.
+					var maxBytesErr *http.MaxBytesError
+					if errors.As(err, &maxBytesErr) {
+						writeError(w, r, http.StatusRequestEntityTooLarge)
+					} else {
+						writeError(w, r, http.StatusBadRequest)
+					}
+					return
+				}
+				body = decoded
+			}
+
+			if len(codings) > 0 {
+				if maxDecodedSize > 0 {
+					body = &limitedReader{reader: body, remaining: maxDecodedSize, limit: maxDecodedSize, exceeded: &exceeded}
+				}
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			}
+
+			r.Body = readCloser{Reader: body, Closer: original}
+
+			si := toStatusInterceptor(w, r)
+			next.ServeHTTP(si, r)
+
+			if exceeded && si.Status == 0 {
+				writeError(si, r, http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
+
+// contentCodings parses a Content-Encoding header into its list of codings,
+// dropping identity. It reports false for codings it cannot decode.
+func contentCodings(header string) ([]string, bool) {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		switch coding = strings.ToLower(strings.TrimSpace(coding)); coding {
+		case "", "identity":
+		case "gzip", "x-gzip", "deflate":
+			codings = append(codings, coding)
+		default:
+			return nil, false
+		}
+	}
+	return codings, true
+}
+
+func newDecoder(coding string, r io.Reader) (io.Reader, error) {
+	if coding == "deflate" {
+		// Some clients send raw deflate streams instead of the zlib format
+		// HTTP specifies, so peek at the header to tell them apart.
+		buffered := bufio.NewReader(r)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	}
+	return gzip.NewReader(r)
+}
+
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// limitedReader fails with an *http.MaxBytesError once more than limit bytes
+// have been read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+	exceeded  *bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: l.limit}
+	}
+
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+	if int64(n) <= l.remaining {
+		l.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(l.remaining)
+	l.remaining = -1
+	*l.exceeded = true
+	return n, &http.MaxBytesError{Limit: l.limit}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,181 @@
+package simplerouter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(data))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		w.WriteHeader(200)
+		w.Write(body)
+	}
+
+	t.Run("decodes gzip bodies", func(t *testing.T) {
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 1024}))
+		router.Post("/echo", echo)
+
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(gzipBytes(t, "hello")))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+
+		if w.Body.String() != "hello" {
+			t.Errorf("Expected 'hello', got %q", w.Body.String())
+		}
+	})
+
+	t.Run("rejects bodies over the wire limit by Content-Length", func(t *testing.T) {
+		called := false
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 4}))
+		router.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+
+		req := httptest.NewRequest("POST", "/echo", strings.NewReader("too large"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if called {
+			t.Error("Expected handler not to run")
+		}
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+
+		expected := `{"instance":"/echo","status":413,"title":"Request Entity Too Large","type":"about:blank"}`
+		if w.Body.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, w.Body.String())
+		}
+	})
+
+	t.Run("stops decompression bombs", func(t *testing.T) {
+		var readErr error
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 1024, MaxDecodedSize: 100}))
+		router.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = io.ReadAll(r.Body)
+		})
+
+		compressed := gzipBytes(t, strings.Repeat("a", 10000))
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(compressed))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var maxErr *http.MaxBytesError
+		if !errors.As(readErr, &maxErr) || maxErr.Limit != 100 {
+			t.Errorf("Expected MaxBytesError with limit 100, got %v", readErr)
+		}
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects compressed bodies over the limit before the handler", func(t *testing.T) {
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 4}))
+		router.Post("/echo", echo)
+
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(gzipBytes(t, "hello")))
+		req.Header.Set("Content-Encoding", "gzip")
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+	})
+
+	t.Run("route option overrides the limit", func(t *testing.T) {
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 4}))
+		router.Route("/api", func(r *Router) {
+			r.With(BodyLimit(1024)).Post("/upload", echo)
+			r.Post("/small", echo)
+		})
+
+		req := httptest.NewRequest("POST", "/api/upload", strings.NewReader("large enough"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+
+		req = httptest.NewRequest("POST", "/api/small", strings.NewReader("large enough"))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects unsupported codings", func(t *testing.T) {
+		router := NewRouter(DecodeBody(BodyOptions{}))
+		router.Post("/echo", echo)
+
+		req := httptest.NewRequest("POST", "/echo", strings.NewReader("data"))
+		req.Header.Set("Content-Encoding", "br")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected status 415, got %d", w.Code)
+		}
+	})
+}
+
+func TestRouteFromContext(t *testing.T) {
+	router := NewRouter()
+
+	var seen RouteInfo
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = RouteFromContext(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	router.Route("/api", func(r *Router) {
+		r.With(BodyLimit(10)).Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	req := httptest.NewRequest("GET", "/api/items/1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen.Pattern != "GET /api/items/{id}" {
+		t.Errorf("Expected the inner route pattern, got %q", seen.Pattern)
+	}
+
+	if seen.BodyLimit != 10 {
+		t.Errorf("Expected BodyLimit 10, got %d", seen.BodyLimit)
+	}
+}
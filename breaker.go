@@ -0,0 +1,359 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through while counting failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits every request to the fallback.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probe requests through to
+	// decide whether to close again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// BreakerOptions configures a Breaker. The zero value is usable.
+type BreakerOptions struct {
+	// FailureRatio is the share of failed requests within Window that trips
+	// the breaker. Defaults to 0.5.
+	FailureRatio float64
+	// MinRequests is the number of requests Window must hold before the
+	// breaker may trip. Defaults to 20.
+	MinRequests int
+	// Window is the rolling period over which requests are counted. Defaults
+	// to 10 seconds; it is split into buckets of at least a nanosecond.
+	Window time.Duration
+	// OpenTimeout is how long the breaker stays open before probing. Defaults
+	// to 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe requests let through while half
+	// open; all of them must succeed to close the breaker. Defaults to 1.
+	HalfOpenProbes int
+	// IsFailure classifies response statuses. Defaults to 5xx.
+	IsFailure func(status int) bool
+	// Fallback serves short-circuited requests. Defaults to a 503 with
+	// Retry-After.
+	Fallback http.Handler
+	// OnStateChange is called, without the breaker locked, after each
+	// transition.
+	OnStateChange func(name string, from, to BreakerState)
+
+	now func() time.Time
+}
+
+const breakerBuckets = 10
+
+// Breaker is a circuit breaker for routes fronting an unreliable dependency.
+// Attach it to routes with the CircuitBreaker route option, which also makes
+// it visible through Router.Routes and Router.BreakerStatus, or use its
+// Middleware directly in a middleware chain.
+type Breaker struct {
+	name string
+	opts BreakerOptions
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	openedAt   time.Time
+	buckets    [breakerBuckets]breakerBucket
+	probes     int
+	successes  int
+}
+
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// BreakerStats is a snapshot of a Breaker.
+type BreakerStats struct {
+	Name     string       `json:"name"`
+	State    BreakerState `json:"state"`
+	Requests int          `json:"requests"`
+	Failures int          `json:"failures"`
+	// OpenedAt is set while the breaker is open or half open.
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
+
+// NewBreaker returns a closed Breaker identified by name in introspection.
+func NewBreaker(name string, opts BreakerOptions) *Breaker {
+	if opts.FailureRatio <= 0 {
+		opts.FailureRatio = 0.5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.Window < breakerBuckets {
+		opts.Window = breakerBuckets
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(status int) bool { return status >= 500 }
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+
+	return &Breaker{name: name, opts: opts}
+}
+
+// CircuitBreaker guards the route with b. Applied to a Route sub-router, every
+// route of the sub-router shares the breaker.
+func CircuitBreaker(b *Breaker) RouteOption {
+	return func(route *RouteInfo) {
+		route.Breaker = b
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() BreakerState {
+	_, unlock := b.lock()
+	defer unlock()
+	return b.state
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	now, unlock := b.lock()
+	defer unlock()
+
+	stats := BreakerStats{Name: b.name, State: b.state}
+	stats.Requests, stats.Failures = b.counts(now)
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// Middleware guards next with the breaker, observing response statuses
+// through the router's status interceptor.
+func (b *Breaker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		generation, ok := b.allow()
+		if !ok {
+			b.fallback(w, r)
+			return
+		}
+
+		si := toStatusInterceptor(w, r)
+		defer func() {
+			if p := recover(); p != nil {
+				b.record(generation, true)
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(si, r)
+
+		status := si.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		b.record(generation, b.opts.IsFailure(status))
+	})
+}
+
+func (b *Breaker) fallback(w http.ResponseWriter, r *http.Request) {
+	if b.opts.Fallback != nil {
+		b.opts.Fallback.ServeHTTP(w, r)
+		return
+	}
+
+	now, unlock := b.lock()
+	retryAfter := b.openedAt.Add(b.opts.OpenTimeout).Sub(now)
+	unlock()
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(retryAfter))))
+	writeError(w, r, http.StatusServiceUnavailable)
+}
+
+// allow reports whether a request may proceed, along with the generation it
+// belongs to so that results from a previous state are ignored.
+func (b *Breaker) allow() (uint64, bool) {
+	_, unlock := b.lock()
+	defer unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return 0, false
+	case BreakerHalfOpen:
+		if b.probes >= b.opts.HalfOpenProbes {
+			return 0, false
+		}
+		b.probes++
+	}
+
+	return b.generation, true
+}
+
+func (b *Breaker) record(generation uint64, failed bool) {
+	now, unlock := b.lock()
+
+	if generation != b.generation {
+		unlock()
+		return
+	}
+
+	from := b.state
+
+	switch b.state {
+	case BreakerClosed:
+		bucket := b.bucket(now)
+		bucket.requests++
+		if failed {
+			bucket.failures++
+		}
+
+		requests, failures := b.counts(now)
+		if requests >= b.opts.MinRequests && float64(failures)/float64(requests) >= b.opts.FailureRatio {
+			b.transition(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.transition(BreakerOpen, now)
+		} else if b.successes++; b.successes >= b.opts.HalfOpenProbes {
+			b.transition(BreakerClosed, now)
+		}
+	}
+
+	to := b.state
+	unlock()
+
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// lock locks the breaker, first moving an open breaker to half open once
+// OpenTimeout has elapsed. The returned function unlocks it and reports that
+// transition.
+func (b *Breaker) lock() (time.Time, func()) {
+	b.mu.Lock()
+
+	now := b.opts.now()
+	halfOpened := b.state == BreakerOpen && !now.Before(b.openedAt.Add(b.opts.OpenTimeout))
+	if halfOpened {
+		b.transition(BreakerHalfOpen, now)
+	}
+
+	return now, func() {
+		b.mu.Unlock()
+		if halfOpened {
+			b.notify(BreakerOpen, BreakerHalfOpen)
+		}
+	}
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(b.name, from, to)
+	}
+}
+
+func (b *Breaker) transition(state BreakerState, now time.Time) {
+	logger.Debug("Breaker transition", "name", b.name, "from", b.state, "to", state)
+
+	b.state = state
+	b.generation++
+	b.probes, b.successes = 0, 0
+
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerClosed:
+		b.buckets = [breakerBuckets]breakerBucket{}
+	}
+}
+
+func (b *Breaker) bucket(now time.Time) *breakerBucket {
+	width := b.opts.Window / breakerBuckets
+	start := now.Truncate(width)
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *Breaker) counts(now time.Time) (requests, failures int) {
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.opts.Window {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+type breakerStatus struct {
+	BreakerStats
+	Routes []string `json:"routes"`
+}
+
+// BreakerStatus is a debug endpoint listing the breakers attached to the
+// Router's routes with the CircuitBreaker option, e.g.
+//
+//	r.Get("/debug/breakers", r.BreakerStatus)
+func (r *Router) BreakerStatus(w http.ResponseWriter, req *http.Request) {
+	var breakers []*Breaker
+	routes := map[*Breaker][]string{}
+
+	for _, route := range r.Routes() {
+		if route.Breaker == nil || route.Alias {
+			continue
+		}
+		if _, ok := routes[route.Breaker]; !ok {
+			breakers = append(breakers, route.Breaker)
+		}
+		routes[route.Breaker] = append(routes[route.Breaker], route.Pattern)
+	}
+
+	statuses := make([]breakerStatus, 0, len(breakers))
+	for _, breaker := range breakers {
+		statuses = append(statuses, breakerStatus{BreakerStats: breaker.Stats(), Routes: routes[breaker]})
+	}
+	slices.SortFunc(statuses, func(a, b breakerStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,182 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+
+	var transitions []string
+	breaker := NewBreaker("upstream", BreakerOptions{
+		MinRequests: 4,
+		OpenTimeout: time.Minute,
+		OnStateChange: func(name string, from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+		now: clock.Now,
+	})
+
+	status := 500
+	router := NewRouter()
+	router.Route("/upstream", func(r *Router) {
+		r.Get("/a", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+		r.Get("/b", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+	})
+	router.With(CircuitBreaker(breaker)).Route("/guarded", func(r *Router) {
+		r.Get("/a", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+		r.Get("/b", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+	})
+
+	request := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+
+	for _, path := range []string{"/guarded/a", "/guarded/b", "/guarded/a", "/guarded/b"} {
+		if code := request(path); code != 500 {
+			t.Fatalf("Expected the upstream failure to pass through, got %d", code)
+		}
+	}
+
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("Expected the breaker to be open, got %s", breaker.State())
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/guarded/a", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while open, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+
+	if code := request("/upstream/a"); code != 500 {
+		t.Errorf("Expected unguarded routes to be unaffected, got %d", code)
+	}
+
+	clock.Advance(time.Minute)
+	if breaker.State() != BreakerHalfOpen {
+		t.Fatalf("Expected the breaker to be half open, got %s", breaker.State())
+	}
+
+	if code := request("/guarded/b"); code != 500 {
+		t.Errorf("Expected the probe to reach the handler, got %d", code)
+	}
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %s", breaker.State())
+	}
+
+	clock.Advance(time.Minute)
+	status = 200
+	if code := request("/guarded/b"); code != 200 {
+		t.Errorf("Expected the probe to succeed, got %d", code)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Errorf("Expected a successful probe to close the breaker, got %s", breaker.State())
+	}
+
+	expected := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transitions %v, got %v", expected, transitions)
+			break
+		}
+	}
+}
+
+func TestBreakerFallback(t *testing.T) {
+	breaker := NewBreaker("flaky", BreakerOptions{
+		MinRequests: 1,
+		Fallback: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte("cached"))
+		}),
+	})
+
+	router := NewRouter()
+	router.Get("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(502)
+	}, breaker.Middleware)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/flaky", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/flaky", nil))
+	if w.Body.String() != "cached" {
+		t.Errorf("Expected the fallback response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestBreakerTinyWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	breaker := NewBreaker("tiny", BreakerOptions{Window: 5 * time.Nanosecond, MinRequests: 3, now: clock.Now})
+
+	router := NewRouter()
+	router.Get("/tiny", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}, breaker.Middleware)
+
+	for range 3 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tiny", nil))
+	}
+	if breaker.State() != BreakerOpen {
+		t.Errorf("Expected the breaker to open, got %s", breaker.State())
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tiny", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestRouterBreakerStatus(t *testing.T) {
+	breaker := NewBreaker("payments", BreakerOptions{})
+
+	router := NewRouter()
+	router.With(CircuitBreaker(breaker)).Post("/payments", func(w http.ResponseWriter, r *http.Request) {})
+	router.Get("/debug/breakers", router.BreakerStatus)
+
+	routes := router.Routes()
+	if routes[0].Breaker != breaker {
+		t.Error("Expected the breaker to be visible on the route")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/debug/breakers", nil))
+
+	var statuses []struct {
+		Name   string   `json:"name"`
+		State  string   `json:"state"`
+		Routes []string `json:"routes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 1 || statuses[0].Name != "payments" || statuses[0].State != "closed" {
+		t.Fatalf("Unexpected breaker status %+v", statuses)
+	}
+
+	if len(statuses[0].Routes) != 1 || statuses[0].Routes[0] != "POST /payments" {
+		t.Errorf("Expected routes [POST /payments], got %v", statuses[0].Routes)
+	}
+}
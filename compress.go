@@ -0,0 +1,366 @@
+package simplerouter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Encoder implements a content coding for the Compress middleware. Encoders
+// beyond the standard library (brotli, zstd, ...) plug in by implementing it.
+type Encoder interface {
+	// Encoding is the token used in Accept-Encoding and Content-Encoding.
+	Encoding() string
+	// NewWriter returns a writer compressing into w. Closing it must flush any
+	// buffered data without closing w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+type gzipEncoder struct{ level int }
+
+func (e gzipEncoder) Encoding() string { return "gzip" }
+
+func (e gzipEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, e.level)
+}
+
+type deflateEncoder struct{ level int }
+
+func (e deflateEncoder) Encoding() string { return "deflate" }
+
+func (e deflateEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, e.level)
+}
+
+// GzipEncoder returns an Encoder for the gzip content coding using one of the
+// compress/gzip levels.
+func GzipEncoder(level int) Encoder {
+	return gzipEncoder{level: level}
+}
+
+// DeflateEncoder returns an Encoder for the deflate content coding, which HTTP
+// defines as the zlib format, using one of the compress/flate levels.
+func DeflateEncoder(level int) Encoder {
+	return deflateEncoder{level: level}
+}
+
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"image/svg+xml",
+}
+
+// CompressOptions configures the Compress middleware. The zero value is usable.
+type CompressOptions struct {
+	// MinSize is the smallest response body, in bytes, that gets compressed.
+	// Defaults to 1024.
+	MinSize int
+	// ContentTypes lists the media types eligible for compression. Entries may
+	// use a wildcard subtype ("text/*") or a wildcard prefix in the subtype
+	// ("application/*+json"). Defaults to common text formats.
+	ContentTypes []string
+	// Encoders lists the supported codings in order of server preference,
+	// which breaks ties between codings the client accepts equally. Defaults
+	// to gzip then deflate.
+	Encoders []Encoder
+}
+
+// Compress returns a middleware compressing response bodies with the best
+// coding acceptable to the client according to Accept-Encoding.
+//
+// Bodies smaller than MinSize, non-eligible content types, responses that
+// already carry a Content-Encoding, HEAD requests and partial content are sent
+// as-is. Flush, Hijack and Push remain available to handlers.
+func Compress(opts CompressOptions) func(http.Handler) http.Handler {
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	if opts.ContentTypes == nil {
+		opts.ContentTypes = defaultCompressibleTypes
+	}
+	if opts.Encoders == nil {
+		opts.Encoders = []Encoder{GzipEncoder(gzip.DefaultCompression), DeflateEncoder(flate.DefaultCompression)}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoder := negotiateEncoding(r.Header.Get("Accept-Encoding"), opts.Encoders)
+			if encoder == nil || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoder: encoder, opts: &opts}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the encoder with the highest q-value in the
+// Accept-Encoding header, or nil when none is acceptable.
+func negotiateEncoding(header string, encoders []Encoder) Encoder {
+	if header == "" {
+		return nil
+	}
+
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, q := parseQuality(part)
+		if coding != "" {
+			weights[coding] = q
+		}
+	}
+
+	wildcard, hasWildcard := weights["*"]
+
+	var best Encoder
+	var bestQ float64
+	for _, encoder := range encoders {
+		q, ok := weights[strings.ToLower(encoder.Encoding())]
+		if !ok {
+			if !hasWildcard {
+				continue
+			}
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+
+	return best
+}
+
+// parseQuality splits a single Accept-* list element into its lowercased value
+// and q-value, which defaults to 1.
+func parseQuality(part string) (string, float64) {
+	value, params, _ := strings.Cut(part, ";")
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		key, raw, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+			q = parsed
+		}
+	}
+
+	return value, q
+}
+
+func matchContentType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if matchMediaType(mediaType, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMediaType reports whether mediaType matches pattern, which may be "*/*",
+// "type/*" or "type/*+suffix".
+func matchMediaType(mediaType, pattern string) bool {
+	if pattern == "*/*" || strings.EqualFold(mediaType, pattern) {
+		return true
+	}
+
+	patternType, patternSub, _ := strings.Cut(strings.ToLower(pattern), "/")
+	mainType, subType, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	if patternType != mainType {
+		return false
+	}
+
+	if patternSub == "*" {
+		return true
+	}
+
+	if suffix, ok := strings.CutPrefix(patternSub, "*"); ok {
+		return strings.HasSuffix(subType, suffix)
+	}
+
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is worth compressing, then either streams it through the encoder or
+// passes it through untouched.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+	opts    *CompressOptions
+
+	status      int
+	buffer      bytes.Buffer
+	decided     bool
+	compressing bool
+	writer      io.WriteCloser
+	hijacked    bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if cw.decided {
+		if cw.compressing {
+			return cw.writer.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buffer.Write(p)
+	if cw.buffer.Len() >= cw.opts.MinSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// decide settles whether to compress based on what has been buffered so far,
+// writes the header and drains the buffer.
+func (cw *compressWriter) decide() error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && cw.buffer.Len() > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buffer.Bytes()))
+	}
+
+	cw.compressing = cw.buffer.Len() >= cw.opts.MinSize &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified &&
+		cw.status != http.StatusPartialContent &&
+		header.Get("Content-Encoding") == "" &&
+		matchContentType(header.Get("Content-Type"), cw.opts.ContentTypes)
+
+	if cw.compressing {
+		writer, err := cw.encoder.NewWriter(cw.ResponseWriter)
+		if err != nil {
+			cw.compressing = false
+		} else {
+			cw.writer = writer
+			header.Set("Content-Encoding", cw.encoder.Encoding())
+			header.Del("Content-Length")
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buffer.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.compressing {
+		_, err = cw.writer.Write(cw.buffer.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buffer.Bytes())
+	}
+	cw.buffer.Reset()
+	return err
+}
+
+// Close finishes the response, flushing any buffered body and the encoder.
+func (cw *compressWriter) Close() error {
+	if cw.hijacked || (cw.status == 0 && cw.buffer.Len() == 0) {
+		return nil
+	}
+
+	if err := cw.decide(); err != nil {
+		return err
+	}
+
+	if cw.compressing {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// Hijacker interface support
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		conn, rw, err := hijacker.Hijack()
+		if err == nil {
+			cw.hijacked = true
+		}
+		return conn, rw, err
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Flusher interface support
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 && cw.buffer.Len() == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if err := cw.decide(); err != nil {
+		return
+	}
+
+	if cw.compressing {
+		if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+			flusher.Flush()
+		}
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Pusher interface support
+func (cw *compressWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := cw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
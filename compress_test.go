@@ -0,0 +1,213 @@
+package simplerouter
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+
+	newRouter := func(body, contentType string) *Router {
+		router := NewRouter(Compress(CompressOptions{}))
+		router.Get("/test", func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.Header().Set("Content-Length", "12345")
+			w.WriteHeader(200)
+			w.Write([]byte(body))
+		})
+		return router
+	}
+
+	t.Run("gzips large eligible responses", func(t *testing.T) {
+		router := newRouter(large, "text/plain; charset=utf-8")
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Expected gzip Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+
+		if w.Header().Get("Content-Length") != "" {
+			t.Errorf("Expected Content-Length to be stripped, got %q", w.Header().Get("Content-Length"))
+		}
+
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+		}
+
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(reader)
+		if string(body) != large {
+			t.Error("Decompressed body does not match")
+		}
+	})
+
+	t.Run("honors q-values", func(t *testing.T) {
+		router := newRouter(large, "application/json")
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate;q=0.8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "deflate" {
+			t.Fatalf("Expected deflate Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+
+		reader, err := zlib.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(reader)
+		if string(body) != large {
+			t.Error("Decompressed body does not match")
+		}
+	})
+
+	t.Run("skips refused codings", func(t *testing.T) {
+		router := newRouter(large, "text/plain")
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0, deflate;q=0")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+
+		if w.Body.String() != large {
+			t.Error("Expected uncompressed body")
+		}
+	})
+
+	t.Run("skips small responses", func(t *testing.T) {
+		router := newRouter("tiny", "text/plain")
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+
+		if w.Body.String() != "tiny" {
+			t.Errorf("Expected 'tiny', got %q", w.Body.String())
+		}
+	})
+
+	t.Run("skips ineligible content types", func(t *testing.T) {
+		router := newRouter(large, "image/png")
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("preserves status codes", func(t *testing.T) {
+		router := NewRouter(Compress(CompressOptions{MinSize: 1}))
+		router.Post("/test", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(201)
+			w.Write([]byte(`{"created": true}`))
+		})
+
+		req := httptest.NewRequest("POST", "/test", nil)
+		req.Header.Set("Accept-Encoding", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 201 {
+			t.Errorf("Expected status 201, got %d", w.Code)
+		}
+
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected gzip Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("flushes through the encoder", func(t *testing.T) {
+		router := NewRouter(Compress(CompressOptions{MinSize: 1}))
+		router.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+		})
+
+		req := httptest.NewRequest("GET", "/stream", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if !w.Flushed {
+			t.Error("Expected the underlying writer to be flushed")
+		}
+
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(reader)
+		if string(body) != "chunk" {
+			t.Errorf("Expected 'chunk', got %q", body)
+		}
+	})
+}
+
+type upperEncoder struct{}
+
+func (upperEncoder) Encoding() string { return "x-upper" }
+
+func (upperEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return upperWriter{w}, nil
+}
+
+type upperWriter struct{ io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) {
+	return u.Writer.Write([]byte(strings.ToUpper(string(p))))
+}
+
+func (u upperWriter) Close() error { return nil }
+
+func TestCompressCustomEncoder(t *testing.T) {
+	router := NewRouter(Compress(CompressOptions{MinSize: 1, Encoders: []Encoder{upperEncoder{}}}))
+	router.Get("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("shout"))
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept-Encoding", "gzip, x-upper")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "x-upper" {
+		t.Errorf("Expected x-upper Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+
+	if w.Body.String() != "SHOUT" {
+		t.Errorf("Expected 'SHOUT', got %q", w.Body.String())
+	}
+}
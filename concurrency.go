@@ -0,0 +1,332 @@
+package simplerouter
+
+import (
+	"container/heap"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConcurrencyOptions configures the ConcurrencyLimit middleware.
+type ConcurrencyOptions struct {
+	// Limit is the number of requests allowed in flight, or the initial limit
+	// when Adaptive is set.
+	Limit int
+	// QueueSize is the number of requests allowed to wait for a slot. Zero
+	// sheds as soon as the limit is reached.
+	QueueSize int
+	// MaxWait bounds the time a request waits in the queue. Defaults to 100ms.
+	MaxWait time.Duration
+	// RetryAfter is advertised to shed clients. Defaults to one second.
+	RetryAfter time.Duration
+	// Adaptive adjusts the limit from the latency and status of completed
+	// requests. Nil keeps the limit fixed.
+	Adaptive LimitAlgorithm
+}
+
+// LimitSample describes a completed request to a LimitAlgorithm.
+type LimitSample struct {
+	// Latency is measured from the moment the request entered the router, so
+	// it includes the time spent queueing.
+	Latency time.Duration
+	// InFlight is the number of requests in flight when this one completed,
+	// including itself.
+	InFlight int
+	// Dropped is set for responses with a 5xx status.
+	Dropped bool
+}
+
+// LimitAlgorithm computes a new concurrency limit after each completed
+// request. Calls are serialized by the limiter, so implementations may keep
+// state without locking.
+type LimitAlgorithm interface {
+	Update(limit int, sample LimitSample) int
+}
+
+// AIMDOptions configures the AIMD LimitAlgorithm.
+type AIMDOptions struct {
+	Min, Max int
+	// Backoff multiplies the limit after a drop. Defaults to 0.9.
+	Backoff float64
+	// Timeout counts slower requests as drops. Zero only counts 5xx responses.
+	Timeout time.Duration
+}
+
+type aimd struct {
+	opts AIMDOptions
+}
+
+// AIMD returns an additive-increase/multiplicative-decrease LimitAlgorithm:
+// the limit grows by one while the limiter is well utilised and shrinks by
+// Backoff whenever a request fails or exceeds Timeout.
+func AIMD(opts AIMDOptions) LimitAlgorithm {
+	if opts.Min <= 0 {
+		opts.Min = 1
+	}
+	if opts.Max <= 0 {
+		opts.Max = math.MaxInt32
+	}
+	if opts.Backoff <= 0 || opts.Backoff >= 1 {
+		opts.Backoff = 0.9
+	}
+	return &aimd{opts: opts}
+}
+
+func (a *aimd) Update(limit int, sample LimitSample) int {
+	if sample.Dropped || (a.opts.Timeout > 0 && sample.Latency > a.opts.Timeout) {
+		limit = int(float64(limit) * a.opts.Backoff)
+	} else if sample.InFlight*2 >= limit {
+		limit++
+	}
+	return min(max(limit, a.opts.Min), a.opts.Max)
+}
+
+// GradientOptions configures the Gradient LimitAlgorithm.
+type GradientOptions struct {
+	Min, Max int
+	// Tolerance is the ratio of short-term to long-term latency accepted
+	// before the limit shrinks. Defaults to 1.5.
+	Tolerance float64
+	// Smoothing weighs each new limit against the previous one. Defaults to
+	// 0.2.
+	Smoothing float64
+}
+
+type gradient struct {
+	opts     GradientOptions
+	shortRTT float64
+	longRTT  float64
+	limit    float64
+}
+
+// Gradient returns a LimitAlgorithm comparing a short-term average of
+// latencies with a long-term baseline: the limit shrinks in proportion to how
+// much slower requests have become and grows by a queue allowance of
+// sqrt(limit) while latency holds steady.
+func Gradient(opts GradientOptions) LimitAlgorithm {
+	if opts.Min <= 0 {
+		opts.Min = 1
+	}
+	if opts.Max <= 0 {
+		opts.Max = math.MaxInt32
+	}
+	if opts.Tolerance < 1 {
+		opts.Tolerance = 1.5
+	}
+	if opts.Smoothing <= 0 || opts.Smoothing > 1 {
+		opts.Smoothing = 0.2
+	}
+	return &gradient{opts: opts}
+}
+
+func (g *gradient) Update(limit int, sample LimitSample) int {
+	rtt := float64(sample.Latency)
+	if g.longRTT == 0 {
+		g.shortRTT, g.longRTT, g.limit = rtt, rtt, float64(limit)
+		return limit
+	}
+
+	g.shortRTT = g.shortRTT*0.9 + rtt*0.1
+	g.longRTT = g.longRTT*0.99 + rtt*0.01
+
+	// Let the baseline recover quickly once latency drops again.
+	if g.longRTT/g.shortRTT > 2 {
+		g.longRTT = g.shortRTT * 2
+	}
+
+	// Do not grow while the limiter is far from saturated.
+	if float64(sample.InFlight) < g.limit/2 && !sample.Dropped {
+		return limit
+	}
+
+	ratio := max(0.5, min(1, g.opts.Tolerance*g.longRTT/g.shortRTT))
+	if sample.Dropped {
+		ratio = 0.5
+	}
+
+	next := g.limit*ratio + math.Sqrt(g.limit)
+	g.limit = g.limit*(1-g.opts.Smoothing) + next*g.opts.Smoothing
+	g.limit = min(max(g.limit, float64(g.opts.Min)), float64(g.opts.Max))
+
+	return int(g.limit)
+}
+
+// ConcurrencyLimit returns a middleware capping the number of requests in
+// flight. Use it on a Router to share the cap between all of its routes, or on
+// a single route.
+//
+// Requests over the limit wait in a queue ordered by route Priority for up to
+// MaxWait. When the queue is full a higher priority request takes the place of
+// the lowest priority waiter. Requests that cannot be admitted are shed with a
+// 503 and Retry-After.
+func ConcurrencyLimit(opts ConcurrencyOptions) func(http.Handler) http.Handler {
+	if opts.Limit <= 0 {
+		panic("simplerouter: ConcurrencyLimit requires a positive Limit")
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = 100 * time.Millisecond
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+
+	limiter := &concurrencyLimiter{opts: opts, limit: opts.Limit}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			priority := 0
+			if route, ok := RouteFromContext(r.Context()); ok {
+				priority = route.Priority
+			}
+
+			if !limiter.acquire(r, priority) {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(opts.RetryAfter)))
+				writeError(w, r, http.StatusServiceUnavailable)
+				return
+			}
+
+			si := toStatusInterceptor(w, r)
+			defer func() {
+				limiter.release(si)
+			}()
+
+			next.ServeHTTP(si, r)
+		})
+	}
+}
+
+type concurrencyLimiter struct {
+	opts ConcurrencyOptions
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	waiters  waiterQueue
+	sequence uint64
+}
+
+type waiter struct {
+	priority int
+	sequence uint64
+	index    int
+	ready    chan struct{}
+	admitted bool
+}
+
+// acquire reports whether the request may proceed, waiting in the queue when
+// the limiter is saturated.
+func (l *concurrencyLimiter) acquire(r *http.Request, priority int) bool {
+	l.mu.Lock()
+
+	if l.inFlight < l.limit && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+
+	if l.opts.QueueSize <= 0 {
+		l.mu.Unlock()
+		return false
+	}
+
+	if len(l.waiters) >= l.opts.QueueSize {
+		lowest := l.waiters.lowest()
+		if lowest.priority >= priority {
+			l.mu.Unlock()
+			return false
+		}
+		heap.Remove(&l.waiters, lowest.index)
+		close(lowest.ready)
+	}
+
+	l.sequence++
+	w := &waiter{priority: priority, sequence: l.sequence, ready: make(chan struct{})}
+	heap.Push(&l.waiters, w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.opts.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !w.admitted && w.index >= 0 {
+		heap.Remove(&l.waiters, w.index)
+	}
+	return w.admitted
+}
+
+func (l *concurrencyLimiter) release(si *statusInterceptor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.opts.Adaptive != nil {
+		l.limit = max(1, l.opts.Adaptive.Update(l.limit, LimitSample{
+			Latency:  si.Elapsed(),
+			InFlight: l.inFlight,
+			Dropped:  si.Status >= 500,
+		}))
+	}
+
+	l.inFlight--
+
+	for l.inFlight < l.limit && len(l.waiters) > 0 {
+		w := heap.Pop(&l.waiters).(*waiter)
+		w.admitted = true
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// waiterQueue is a heap of waiters, highest priority first and FIFO within a
+// priority.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].sequence < q[j].sequence
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// lowest returns the waiter that would be admitted last.
+func (q waiterQueue) lowest() *waiter {
+	lowest := q[0]
+	for _, w := range q[1:] {
+		if w.priority < lowest.priority || (w.priority == lowest.priority && w.sequence > lowest.sequence) {
+			lowest = w
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,170 @@
+package simplerouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	t.Run("sheds requests over the limit", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		router := NewRouter(ConcurrencyLimit(ConcurrencyOptions{Limit: 1, RetryAfter: 2 * time.Second}))
+		router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(200)
+		})
+
+		done := make(chan int)
+		go func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+			done <- w.Code
+		}()
+		<-started
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+
+		if w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Expected Retry-After 2, got %q", w.Header().Get("Retry-After"))
+		}
+
+		close(release)
+		if code := <-done; code != 200 {
+			t.Errorf("Expected first request to succeed, got %d", code)
+		}
+	})
+
+	t.Run("queues requests for a bounded time", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{}, 2)
+
+		router := NewRouter(ConcurrencyLimit(ConcurrencyOptions{Limit: 1, QueueSize: 1, MaxWait: time.Second}))
+		router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(200)
+		})
+
+		var wg sync.WaitGroup
+		codes := make(chan int, 2)
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+				codes <- w.Code
+			}()
+		}
+
+		<-started
+		time.Sleep(20 * time.Millisecond)
+
+		// A third request finds the queue full.
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+
+		close(release)
+		wg.Wait()
+		close(codes)
+
+		for code := range codes {
+			if code != 200 {
+				t.Errorf("Expected queued requests to succeed, got %d", code)
+			}
+		}
+	})
+
+	t.Run("higher priority routes displace queued requests", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{}, 3)
+
+		router := NewRouter(ConcurrencyLimit(ConcurrencyOptions{Limit: 1, QueueSize: 1, MaxWait: time.Second}))
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(200)
+		}
+		router.With(Priority(-1)).Get("/batch", handler)
+		router.With(Priority(10)).Get("/checkout", handler)
+
+		results := make(chan string, 3)
+		send := func(path string) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			results <- path + " " + http.StatusText(w.Code)
+		}
+
+		go send("/batch")
+		<-started
+		go send("/batch")
+		time.Sleep(20 * time.Millisecond)
+		go send("/checkout")
+
+		if result := <-results; result != "/batch Service Unavailable" {
+			t.Errorf("Expected the queued batch request to be shed, got %q", result)
+		}
+
+		close(release)
+		got := map[string]bool{<-results: true, <-results: true}
+		if !got["/batch OK"] || !got["/checkout OK"] {
+			t.Errorf("Expected batch and checkout to succeed, got %v", got)
+		}
+	})
+}
+
+func TestAIMD(t *testing.T) {
+	algorithm := AIMD(AIMDOptions{Min: 2, Max: 12, Timeout: 100 * time.Millisecond})
+
+	if limit := algorithm.Update(10, LimitSample{Latency: time.Millisecond, InFlight: 10}); limit != 11 {
+		t.Errorf("Expected growth to 11, got %d", limit)
+	}
+
+	if limit := algorithm.Update(12, LimitSample{Latency: time.Millisecond, InFlight: 12}); limit != 12 {
+		t.Errorf("Expected the limit to stay at Max, got %d", limit)
+	}
+
+	if limit := algorithm.Update(10, LimitSample{Latency: time.Millisecond, InFlight: 1}); limit != 10 {
+		t.Errorf("Expected no growth while underutilised, got %d", limit)
+	}
+
+	if limit := algorithm.Update(10, LimitSample{Latency: time.Second, InFlight: 10}); limit != 9 {
+		t.Errorf("Expected slow requests to back off to 9, got %d", limit)
+	}
+
+	if limit := algorithm.Update(2, LimitSample{Dropped: true}); limit != 2 {
+		t.Errorf("Expected the limit to stay at Min, got %d", limit)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	algorithm := Gradient(GradientOptions{Min: 1, Max: 100})
+
+	limit := 20
+	for range 50 {
+		limit = algorithm.Update(limit, LimitSample{Latency: 10 * time.Millisecond, InFlight: limit})
+	}
+	steady := limit
+
+	for range 50 {
+		limit = algorithm.Update(limit, LimitSample{Latency: 200 * time.Millisecond, InFlight: limit})
+	}
+
+	if limit >= steady {
+		t.Errorf("Expected the limit to shrink when latency rises, got %d from %d", limit, steady)
+	}
+}
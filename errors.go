@@ -0,0 +1,166 @@
+package simplerouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HandlerFunc is an http.HandlerFunc that returns an error instead of writing
+// its own error responses. Returned errors are mapped to a response by the
+// ErrorHandler of the router serving the request.
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := fn(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
+
+// Func adapts an error-returning handler for the route methods of a Router:
+//
+//	r.Get("/users/{id}", simplerouter.Func(func(w http.ResponseWriter, r *http.Request) error {
+//		user, err := users.Find(r.PathValue("id"))
+//		if err != nil {
+//			return err
+//		}
+//		return json.NewEncoder(w).Encode(user)
+//	}))
+func Func(fn HandlerFunc) http.HandlerFunc {
+	return fn.ServeHTTP
+}
+
+// ErrorHandler writes the response for an error.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Error is an error carrying the HTTP status of the response it should
+// produce, with an optional machine-readable code.
+type Error struct {
+	Status int
+	// Code is a stable identifier clients can match on, such as
+	// "user_not_found".
+	Code string
+	// Message is shown to clients. It defaults to the status text.
+	Message string
+	// Err is the underlying cause. It is not shown to clients.
+	Err error
+
+	// text is the full message formatted by Errorf, cause included, which
+	// only Error reports.
+	text string
+}
+
+// NewError returns an Error with the given status and message.
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+// Errorf returns an Error with the given status and a formatted message. A %w
+// verb also sets the wrapped cause, whose text is kept out of Message: with
+// "load user: %w", clients see "load user" while Error reports the cause too.
+func Errorf(status int, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
+	cause := errors.Unwrap(err)
+	if cause == nil {
+		return &Error{Status: status, Message: err.Error()}
+	}
+	message, ok := strings.CutSuffix(err.Error(), cause.Error())
+	if !ok {
+		message = ""
+	}
+	return &Error{Status: status, Message: strings.TrimRight(message, ": "), Err: cause, text: err.Error()}
+}
+
+func (e *Error) Error() string {
+	if e.text != "" {
+		return e.text
+	}
+	message := e.message()
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCode returns a copy of the error carrying code.
+func (e *Error) WithCode(code string) *Error {
+	copied := *e
+	copied.Code = code
+	return &copied
+}
+
+func (e *Error) message() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.Status)
+}
+
+// StatusOf returns the HTTP status an error maps to: the status of an *Error
+// or *Problem in its chain, or of the Problem of an error describing one, 413
+// for *http.MaxBytesError, 503 for an expired context deadline, or 500.
+func StatusOf(err error) int {
+	var httpErr *Error
+	if errors.As(err, &httpErr) && httpErr.Status != 0 {
+		return httpErr.Status
+	}
+
+	var problem *Problem
+	if errors.As(err, &problem) && problem.Status != 0 {
+		return problem.Status
+	}
+
+	var described problemer
+	if errors.As(err, &described) {
+		if status := described.Problem().Status; status != 0 {
+			return status
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// DefaultErrorHandler responds with an RFC 9457 problem details object built by
+// ProblemOf, negotiated to plain text or HTML when the client prefers them.
+// Messages of errors that are not an *Error or *Problem are hidden behind the
+// status text.
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	problem := ProblemOf(err)
+	if problem.Status >= 500 {
+		logger.Debug("Handler error", "path", r.URL.Path, "status", problem.Status, "error", err)
+	}
+	problem.Write(w, r)
+}
+
+type errorHandlerContextKey struct{}
+
+// WriteError responds to err with the ErrorHandler of the router serving r,
+// or DefaultErrorHandler. Plain http.HandlerFunc handlers can use it to get the
+// same error responses as HandlerFunc ones.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	handler, _ := r.Context().Value(errorHandlerContextKey{}).(ErrorHandler)
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	handler(w, r, err)
+}
+
+// writeError responds with an error generated by the router itself.
+func writeError(w http.ResponseWriter, r *http.Request, status int) {
+	WriteError(w, r, &Error{Status: status})
+}
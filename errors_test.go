@@ -0,0 +1,185 @@
+package simplerouter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	errNotFound := errors.New("user not found")
+
+	router := NewRouter()
+	router.Get("/users/{id}", Func(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.PathValue("id") {
+		case "missing":
+			return NewError(http.StatusNotFound, "User Not Found").WithCode("user_not_found")
+		case "wrapped":
+			return Errorf(http.StatusBadGateway, "upstream: %w", errNotFound)
+		case "failing":
+			return Errorf(http.StatusInternalServerError, "load user: %w", errors.New("pq: password authentication failed"))
+		case "broken":
+			return errors.New("database password is hunter2")
+		}
+		w.WriteHeader(200)
+		w.Write([]byte("user"))
+		return nil
+	}))
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"/users/1", 200, "user"},
+		{"/users/missing", 404, `{"code":"user_not_found","detail":"User Not Found","instance":"/users/missing","status":404,"title":"Not Found","type":"about:blank"}`},
+		{"/users/wrapped", 502, `{"detail":"upstream","instance":"/users/wrapped","status":502,"title":"Bad Gateway","type":"about:blank"}`},
+		{"/users/failing", 500, `{"detail":"load user","instance":"/users/failing","status":500,"title":"Internal Server Error","type":"about:blank"}`},
+		{"/users/broken", 500, `{"instance":"/users/broken","status":500,"title":"Internal Server Error","type":"about:blank"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	tests := []struct {
+		err      *Error
+		expected string
+	}{
+		{Errorf(http.StatusBadRequest, "bad: %w", io.EOF), "bad: EOF"},
+		{Errorf(http.StatusBadRequest, "bad: %v", io.EOF), "bad: EOF"},
+		{Errorf(http.StatusBadRequest, "bad: %w", io.EOF).WithCode("bad"), "bad: EOF"},
+		{Errorf(http.StatusBadRequest, "%w while reading", io.EOF), "EOF while reading"},
+		{&Error{Status: http.StatusBadGateway, Message: "upstream", Err: io.EOF}, "upstream: EOF"},
+		{NewError(http.StatusNotFound, ""), "Not Found"},
+	}
+
+	for _, test := range tests {
+		if message := test.err.Error(); message != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, message)
+		}
+	}
+	if !errors.Is(Errorf(http.StatusBadRequest, "bad: %w", io.EOF), io.EOF) {
+		t.Error("Expected the cause to be wrapped")
+	}
+}
+
+func TestRouterErrorHandler(t *testing.T) {
+	custom := func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(StatusOf(err))
+		fmt.Fprintf(w, "custom: %v", err)
+	}
+
+	t.Run("maps returned errors", func(t *testing.T) {
+		router := NewRouter()
+		router.SetErrorHandler(custom)
+		router.Get("/fail", Func(func(w http.ResponseWriter, r *http.Request) error {
+			return NewError(http.StatusConflict, "taken")
+		}))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/fail", nil))
+
+		if w.Code != http.StatusConflict || w.Body.String() != "custom: taken" {
+			t.Errorf("Expected custom 409 response, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("is inherited by sub-routers", func(t *testing.T) {
+		router := NewRouter()
+		router.Route("/api", func(r *Router) {
+			r.Get("/fail", Func(func(w http.ResponseWriter, r *http.Request) error {
+				return NewError(http.StatusTeapot, "")
+			}))
+		})
+		router.SetErrorHandler(custom)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/fail", nil))
+
+		if w.Body.String() != "custom: I'm a teapot" {
+			t.Errorf("Expected the parent error handler, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("sub-routers can override it", func(t *testing.T) {
+		router := NewRouter()
+		router.SetErrorHandler(custom)
+		router.Route("/api", func(r *Router) {
+			r.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(StatusOf(err))
+				w.Write([]byte("api"))
+			})
+			r.Get("/fail", Func(func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("boom")
+			}))
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/fail", nil))
+
+		if w.Code != 500 || w.Body.String() != "api" {
+			t.Errorf("Expected the sub-router error handler, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("handles router generated errors", func(t *testing.T) {
+		router := NewRouter(DecodeBody(BodyOptions{MaxSize: 1}))
+		router.SetErrorHandler(custom)
+		router.Post("/upload", func(w http.ResponseWriter, r *http.Request) {})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/upload", strings.NewReader("large")))
+
+		if w.Code != 413 || w.Body.String() != "custom: Request Entity Too Large" {
+			t.Errorf("Expected custom 413 response, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("maps body limit errors to 413", func(t *testing.T) {
+		router := NewRouter()
+		router.Post("/upload", Func(func(w http.ResponseWriter, r *http.Request) error {
+			_, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1))
+			return err
+		}))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/upload", strings.NewReader("large")))
+
+		if w.Code != 413 {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+	})
+
+	t.Run("WriteError is available to plain handlers", func(t *testing.T) {
+		router := NewRouter()
+		router.SetErrorHandler(custom)
+		router.Get("/plain", func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, NewError(http.StatusForbidden, "nope"))
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/plain", nil))
+
+		if w.Code != 403 || w.Body.String() != "custom: nope" {
+			t.Errorf("Expected custom 403 response, got %d %q", w.Code, w.Body.String())
+		}
+	})
+}
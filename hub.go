@@ -0,0 +1,318 @@
+package simplerouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Message is published to the subscribers of a topic.
+type Message struct {
+	Topic string
+	// ID and Event become the id and type of server-sent events.
+	ID    string
+	Event string
+	Data  []byte
+}
+
+// Broker carries messages between hubs, such as the hubs of several server
+// instances. Implementations must not hold locks while delivering, as
+// deliveries may subscribe or unsubscribe.
+type Broker interface {
+	// Publish delivers msg to every subscriber of its topic, including those
+	// of the publishing hub.
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe calls deliver with the messages published to topic until
+	// unsubscribe is called.
+	Subscribe(ctx context.Context, topic string, deliver func(Message)) (unsubscribe func(), err error)
+}
+
+// MemoryBroker is a Broker delivering messages within the process.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]map[*func(Message)]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: map[string]map[*func(Message)]struct{}{}}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	deliveries := make([]func(Message), 0, len(b.topics[msg.Topic]))
+	for deliver := range b.topics[msg.Topic] {
+		deliveries = append(deliveries, *deliver)
+	}
+	b.mu.Unlock()
+
+	for _, deliver := range deliveries {
+		deliver(msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string, deliver func(Message)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := &deliver
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[*func(Message)]struct{}{}
+	}
+	b.topics[topic][key] = struct{}{}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.topics[topic], key)
+		if len(b.topics[topic]) == 0 {
+			delete(b.topics, topic)
+		}
+	}, nil
+}
+
+// ErrSlowConsumer is the error of a subscription evicted because its buffer
+// was full.
+var ErrSlowConsumer = errors.New("hub: slow consumer evicted")
+
+// HubOptions configures a Hub. The zero value is usable.
+type HubOptions struct {
+	// Broker carries published messages. Defaults to a MemoryBroker.
+	Broker Broker
+	// BufferSize is the number of messages buffered for each subscriber;
+	// subscribers falling further behind are evicted. Defaults to 64.
+	BufferSize int
+}
+
+// Hub broadcasts messages to the subscribers of topics, typically the event
+// streams and WebSocket connections of clients:
+//
+//	hub := NewHub(HubOptions{})
+//	r.Get("/rooms/{id}/events", SSE(SSEOptions{}, func(r *http.Request, s *EventStream) error {
+//		return hub.ServeEvents(s, PathTopic(r, "rooms/{id}"))
+//	}))
+//	r.Post("/rooms/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+//		body, _ := io.ReadAll(r.Body)
+//		hub.Publish(r.Context(), Message{Topic: PathTopic(r, "rooms/{id}"), Data: body})
+//	})
+//
+// Each subscriber has a bounded buffer; a subscriber whose buffer is full
+// when a message arrives is evicted rather than slowing down the others.
+type Hub struct {
+	opts HubOptions
+
+	mu     sync.Mutex
+	topics map[string]*hubTopic
+}
+
+type hubTopic struct {
+	subscriptions map[*Subscription]struct{}
+	unsubscribe   func()
+}
+
+// Subscription receives the messages published to a topic.
+type Subscription struct {
+	hub      *Hub
+	topic    string
+	messages chan Message
+	// err and closed are guarded by hub.mu.
+	err    error
+	closed bool
+}
+
+func NewHub(opts HubOptions) *Hub {
+	if opts.Broker == nil {
+		opts.Broker = NewMemoryBroker()
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+	return &Hub{opts: opts, topics: map[string]*hubTopic{}}
+}
+
+// PathTopic expands the wildcards of format, such as "rooms/{id}", with the
+// path values of r.
+func PathTopic(r *http.Request, format string) string {
+	var topic strings.Builder
+	for {
+		start := strings.IndexByte(format, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(format[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		topic.WriteString(format[:start])
+		topic.WriteString(r.PathValue(strings.TrimSuffix(format[start+1:end], "...")))
+		format = format[end+1:]
+	}
+	topic.WriteString(format)
+	return topic.String()
+}
+
+// Subscribe starts receiving the messages published to topic. The
+// subscription must be closed when no longer needed.
+func (h *Hub) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.topics[topic]
+	if !ok {
+		unsubscribe, err := h.opts.Broker.Subscribe(ctx, topic, h.deliver)
+		if err != nil {
+			return nil, err
+		}
+		t = &hubTopic{subscriptions: map[*Subscription]struct{}{}, unsubscribe: unsubscribe}
+		h.topics[topic] = t
+	}
+
+	s := &Subscription{hub: h, topic: topic, messages: make(chan Message, h.opts.BufferSize)}
+	t.subscriptions[s] = struct{}{}
+	return s, nil
+}
+
+// Publish sends msg to the subscribers of its topic through the broker.
+func (h *Hub) Publish(ctx context.Context, msg Message) error {
+	return h.opts.Broker.Publish(ctx, msg)
+}
+
+// Subscribers returns the number of local subscribers of topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.topics[topic]; ok {
+		return len(t.subscriptions)
+	}
+	return 0
+}
+
+func (h *Hub) deliver(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.topics[msg.Topic]
+	if !ok {
+		return
+	}
+	for s := range t.subscriptions {
+		select {
+		case s.messages <- msg:
+		default:
+			logger.Debug("Hub subscriber evicted", "topic", msg.Topic, "buffered", len(s.messages))
+			s.err = ErrSlowConsumer
+			h.remove(s)
+		}
+	}
+}
+
+// remove ends a subscription, unsubscribing from the broker after the last
+// one of its topic. h.mu must be held.
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.messages)
+
+	t := h.topics[s.topic]
+	delete(t.subscriptions, s)
+	if len(t.subscriptions) == 0 {
+		delete(h.topics, s.topic)
+		t.unsubscribe()
+	}
+}
+
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Messages returns the channel of the messages received, closed when the
+// subscription is closed or evicted.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Err returns ErrSlowConsumer once the subscription was evicted.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// ServeEvents sends the messages published to topic to an event stream until
+// the client goes away. It returns ErrSlowConsumer if the client is evicted;
+// with a replay buffer, the client then resumes where it left off when it
+// reconnects.
+func (h *Hub) ServeEvents(s *EventStream, topic string) error {
+	sub, err := h.Subscribe(s.ctx, topic)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-s.Done():
+			return nil
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return sub.Err()
+			}
+			if err := s.Send(Event{ID: msg.ID, Event: msg.Event, Data: string(msg.Data)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ServeWebSocket sends the messages published to topic to a WebSocket
+// connection, as text messages if they are valid UTF-8, until the client
+// closes it. Messages from the client are discarded. An evicted client is
+// disconnected with ClosePolicyViolation and ErrSlowConsumer is returned.
+func (h *Hub) ServeWebSocket(conn *WebSocketConn, topic string) error {
+	sub, err := h.Subscribe(context.Background(), topic)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-closed:
+			return err
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				conn.Close(ClosePolicyViolation, "slow consumer")
+				return sub.Err()
+			}
+			kind := BinaryMessage
+			if utf8.Valid(msg.Data) {
+				kind = TextMessage
+			}
+			if err := conn.WriteMessage(kind, msg.Data); err != nil {
+				return err
+			}
+		}
+	}
+}
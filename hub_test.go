@@ -0,0 +1,259 @@
+package simplerouter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker stands in for a networked broker: it delivers messages
+// asynchronously, in order, to the hubs of several simulated instances.
+type fakeBroker struct {
+	mu        sync.Mutex
+	published []Message
+	subs      map[string][]*func(Message)
+	queue     chan Message
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	b := &fakeBroker{subs: map[string][]*func(Message){}, queue: make(chan Message, 100)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range b.queue {
+			b.mu.Lock()
+			var deliveries []func(Message)
+			for _, deliver := range b.subs[msg.Topic] {
+				deliveries = append(deliveries, *deliver)
+			}
+			b.mu.Unlock()
+			for _, deliver := range deliveries {
+				deliver(msg)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(b.queue)
+		<-done
+	})
+	return b
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	b.published = append(b.published, msg)
+	b.mu.Unlock()
+	b.queue <- msg
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(ctx context.Context, topic string, deliver func(Message)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := &deliver
+	b.subs[topic] = append(b.subs[topic], key)
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subs[topic] {
+			if sub == key {
+				b.subs[topic] = append(b.subs[topic][:i], b.subs[topic][i+1:]...)
+				break
+			}
+		}
+	}, nil
+}
+
+func (b *fakeBroker) subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[topic])
+}
+
+func receive(t *testing.T, sub *Subscription) Message {
+	t.Helper()
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a message")
+		return Message{}
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub(HubOptions{})
+	ctx := context.Background()
+
+	a, _ := hub.Subscribe(ctx, "rooms/1")
+	b, _ := hub.Subscribe(ctx, "rooms/1")
+	other, _ := hub.Subscribe(ctx, "rooms/2")
+	defer other.Close()
+
+	hub.Publish(ctx, Message{Topic: "rooms/1", Data: []byte("hello")})
+	for _, sub := range []*Subscription{a, b} {
+		if msg := receive(t, sub); string(msg.Data) != "hello" {
+			t.Errorf("Expected the message, got %q", msg.Data)
+		}
+	}
+	if len(other.Messages()) != 0 {
+		t.Error("Expected other topics not to receive the message")
+	}
+
+	a.Close()
+	a.Close()
+	if _, ok := <-a.Messages(); ok || hub.Subscribers("rooms/1") != 1 {
+		t.Error("Expected a closed subscription to stop receiving")
+	}
+	b.Close()
+	if hub.Subscribers("rooms/1") != 0 || len(hub.opts.Broker.(*MemoryBroker).topics) != 1 {
+		t.Error("Expected the hub to unsubscribe from the broker after the last subscriber")
+	}
+}
+
+func TestHubSlowConsumer(t *testing.T) {
+	hub := NewHub(HubOptions{BufferSize: 2})
+	ctx := context.Background()
+
+	slow, _ := hub.Subscribe(ctx, "ticks")
+	fast, _ := hub.Subscribe(ctx, "ticks")
+	defer fast.Close()
+
+	for i := range 3 {
+		hub.Publish(ctx, Message{Topic: "ticks", Data: []byte(fmt.Sprint(i))})
+		receive(t, fast)
+	}
+
+	var received []string
+	for msg := range slow.Messages() {
+		received = append(received, string(msg.Data))
+	}
+	if strings.Join(received, ",") != "0,1" || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("Expected the slow subscriber to be evicted after its buffer, got %v %v", received, slow.Err())
+	}
+	if fast.Err() != nil || hub.Subscribers("ticks") != 1 {
+		t.Error("Expected the other subscriber to be unaffected")
+	}
+	slow.Close()
+}
+
+func TestHubBroker(t *testing.T) {
+	broker := newFakeBroker(t)
+	ctx := context.Background()
+	instances := []*Hub{NewHub(HubOptions{Broker: broker}), NewHub(HubOptions{Broker: broker})}
+
+	var subs []*Subscription
+	for _, hub := range instances {
+		sub, err := hub.Subscribe(ctx, "orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	if broker.subscribers("orders") != 2 {
+		t.Errorf("Expected one broker subscription per instance, got %d", broker.subscribers("orders"))
+	}
+
+	instances[0].Publish(ctx, Message{Topic: "orders", ID: "1", Data: []byte("created")})
+	for _, sub := range subs {
+		if msg := receive(t, sub); msg.ID != "1" || string(msg.Data) != "created" {
+			t.Errorf("Expected the message on every instance, got %+v", msg)
+		}
+	}
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+	if broker.subscribers("orders") != 0 || len(broker.published) != 1 {
+		t.Error("Expected the instances to unsubscribe from the broker")
+	}
+}
+
+func TestPathTopic(t *testing.T) {
+	r := httptest.NewRequest("GET", "/rooms/42/files/a/b", nil)
+	r.SetPathValue("id", "42")
+	r.SetPathValue("path", "a/b")
+
+	for format, expected := range map[string]string{
+		"rooms/{id}":                 "rooms/42",
+		"rooms/{id}/files/{path...}": "rooms/42/files/a/b",
+		"lobby":                      "lobby",
+		"broken/{id":                 "broken/{id",
+	} {
+		if topic := PathTopic(r, format); topic != expected {
+			t.Errorf("Expected %q to give %q, got %q", format, expected, topic)
+		}
+	}
+}
+
+func TestHubServeEvents(t *testing.T) {
+	hub := NewHub(HubOptions{})
+	router := NewRouter()
+	router.Get("/rooms/{id}/events", SSE(SSEOptions{}, func(r *http.Request, s *EventStream) error {
+		return hub.ServeEvents(s, PathTopic(r, "rooms/{id}"))
+	}))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/rooms/7/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	waitFor(t, func() bool { return hub.Subscribers("rooms/7") == 1 })
+	hub.Publish(ctx, Message{Topic: "rooms/7", ID: "9", Event: "chat", Data: []byte("hi\nthere")})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 4 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if strings.Join(lines, "") != "id: 9\nevent: chat\ndata: hi\ndata: there\n" {
+		t.Errorf("Expected the message as an event, got %q", lines)
+	}
+
+	cancel()
+	waitFor(t, func() bool { return hub.Subscribers("rooms/7") == 0 })
+}
+
+func TestHubServeWebSocket(t *testing.T) {
+	hub := NewHub(HubOptions{BufferSize: 1})
+	router := NewRouter()
+	router.WebSocket("/rooms/{id}/ws", WebSocketOptions{}, func(r *http.Request, conn *WebSocketConn) error {
+		return hub.ServeWebSocket(conn, PathTopic(r, "rooms/{id}"))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/rooms/3/ws", nil)
+	waitFor(t, func() bool { return hub.Subscribers("rooms/3") == 1 })
+
+	ctx := context.Background()
+	hub.Publish(ctx, Message{Topic: "rooms/3", Data: []byte("text")})
+	if opcode, payload, _, _ := client.receive(); opcode != wsText || string(payload) != "text" {
+		t.Errorf("Expected a text message, got %d %q", opcode, payload)
+	}
+	hub.Publish(ctx, Message{Topic: "rooms/3", Data: []byte{0xff}})
+	if opcode, _, _, _ := client.receive(); opcode != wsBinary {
+		t.Errorf("Expected a binary message, got %d", opcode)
+	}
+
+	client.send(wsClose, closePayload(CloseNormal, ""), true, false)
+	client.expectClose(CloseNormal)
+	waitFor(t, func() bool { return hub.Subscribers("rooms/3") == 0 })
+}
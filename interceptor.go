@@ -4,15 +4,21 @@ import (
 	"bufio"
 	"net"
 	"net/http"
+	"time"
 )
 
 type statusInterceptor struct {
 	http.ResponseWriter
 	originalPath string
+	start        time.Time
 	Status int
 }
 
 func (wrapper *statusInterceptor) WriteHeader(code int) {
+	if wrapper.Status == 0 && code >= 200 {
+		wrapper.Status = code
+	}
+
 	if code == http.StatusMovedPermanently {
 		location := wrapper.Header().Get("Location")
 		if location == wrapper.originalPath + "/" {
@@ -24,6 +30,13 @@ func (wrapper *statusInterceptor) WriteHeader(code int) {
 	wrapper.ResponseWriter.WriteHeader(code)
 }
 
+func (wrapper *statusInterceptor) Write(b []byte) (int, error) {
+	if wrapper.Status == 0 {
+		wrapper.Status = http.StatusOK
+	}
+	return wrapper.ResponseWriter.Write(b)
+}
+
 // Hijacker interface support
 func (wrapper *statusInterceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hijacker, ok := wrapper.ResponseWriter.(http.Hijacker); ok {
@@ -47,6 +60,16 @@ func (wrapper *statusInterceptor) Push(target string, opts *http.PushOptions) er
 	return http.ErrNotSupported
 }
 
+// Elapsed is the time since the request entered the router
+func (wrapper *statusInterceptor) Elapsed() time.Duration {
+	return time.Since(wrapper.start)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (wrapper *statusInterceptor) Unwrap() http.ResponseWriter {
+	return wrapper.ResponseWriter
+}
+
 func toStatusInterceptor(w http.ResponseWriter, r *http.Request) *statusInterceptor {
 	if si, ok := w.(*statusInterceptor); ok {
 		return si
@@ -54,5 +77,6 @@ func toStatusInterceptor(w http.ResponseWriter, r *http.Request) *statusIntercep
 	return &statusInterceptor{
 		ResponseWriter: w,
 		originalPath:   r.URL.Path,
+		start:          time.Now(),
 	}
 }
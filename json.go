@@ -0,0 +1,200 @@
+package simplerouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// DefaultJSONBodyLimit is the largest request body JSON handlers decode,
+// unless the route sets a BodyLimit.
+const DefaultJSONBodyLimit = 1 << 20
+
+// StatusCoder is implemented by responses of JSON handlers that choose their
+// own status code, such as 201 for a created resource.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// JSON adapts a typed function into a handler for the route methods of a
+// Router:
+//
+//	r.Post("/users", simplerouter.JSON(func(ctx context.Context, in CreateUser) (*User, error) {
+//		return users.Create(ctx, in)
+//	}))
+//
+// The request body is decoded into Req, rejecting unknown fields, trailing
+// data and bodies larger than DefaultJSONBodyLimit or the BodyLimit of the
+// route. An empty body leaves Req as its zero value. Decode failures respond
+// 400, other content types 415 and oversized bodies 413. Fields of a struct Req
+// tagged for Bind are populated from the rest of the request, and the request
+// is checked with Validate before fn runs. Errors returned by fn go to the
+// ErrorHandler of the router.
+//
+// The response is encoded with status 200, or the status the returned Resp
+// reports if it implements StatusCoder. A nil pointer or interface response
+// writes 204 with no body, while nil slices and maps are encoded as [] and {}.
+// Routes registered with HandleJSON also document Req and Resp.
+func JSON[Req, Resp any](fn func(context.Context, Req) (Resp, error)) http.HandlerFunc {
+	return Func(func(w http.ResponseWriter, r *http.Request) error {
+		var in Req
+		if err := bind(w, r, &in, true); err != nil {
+			return err
+		}
+		if err := Validate(&in); err != nil {
+			return err
+		}
+
+		out, err := fn(r.Context(), in)
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, out)
+	})
+}
+
+// HandleJSON registers JSON(fn) as the handler of method and path on r,
+// running chain around it, and documents Req and Resp as the request and
+// response types of the route in the OpenAPI document of the Router:
+//
+//	HandleJSON(r, http.MethodPost, "/users", func(ctx context.Context, in CreateUser) (*User, error) {
+//		return users.Create(ctx, in)
+//	})
+//
+// The response is documented with status 200. Routes whose Resp implements
+// StatusCoder document their status with Returns, since it depends on the
+// value returned:
+//
+//	HandleJSON(r.With(Returns[*User](http.StatusCreated)), http.MethodPost, "/users", createUser)
+func HandleJSON[Req, Resp any](r *Router, method, path string, fn func(context.Context, Req) (Resp, error), chain ...middleware) {
+	r.withDefaults(Accepts[Req](), Returns[Resp](http.StatusOK)).handle(method, path, JSON(fn), chain)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !isJSONMediaType(mediaType) {
+			return Errorf(http.StatusUnsupportedMediaType, "expected a JSON body, got %q", contentType)
+		}
+	}
+
+	limit := int64(DefaultJSONBodyLimit)
+	if route, ok := RouteFromContext(r.Context()); ok && route.BodyLimit > 0 {
+		limit = route.BodyLimit
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return jsonDecodeError(err)
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return jsonDecodeError(err)
+		}
+		return NewError(http.StatusBadRequest, "request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonDecodeError turns a decoding failure into a client error describing
+// what was wrong with the body.
+func jsonDecodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &Error{Status: http.StatusRequestEntityTooLarge, Err: err}
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return Errorf(http.StatusBadRequest, "malformed JSON at offset %d: %w", syntaxErr.Offset, err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return Errorf(http.StatusBadRequest, "malformed JSON: %w", err)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return Errorf(http.StatusBadRequest, "invalid value for %q: expected %s: %w", typeErr.Field, typeErr.Type, err)
+		}
+		return Errorf(http.StatusBadRequest, "invalid JSON value: expected %s: %w", typeErr.Type, err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return Errorf(http.StatusBadRequest, "unknown field %s", field)
+	}
+
+	return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid JSON body: %v", err), Err: err}
+}
+
+// writeJSON encodes v before writing anything, so encoding failures can still
+// respond with an error.
+func writeJSON(w http.ResponseWriter, v any) error {
+	if isNil(v) {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	v = emptyCollection(v)
+
+	status := http.StatusOK
+	if coder, ok := v.(StatusCoder); ok {
+		status = coder.StatusCode()
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		return fmt.Errorf("encoding response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+	return nil
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch value := reflect.ValueOf(v); value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+// emptyCollection replaces a nil slice or map with an empty one, so lists
+// without results are encoded as [] and {} rather than null.
+func emptyCollection(v any) any {
+	switch value := reflect.ValueOf(v); value.Kind() {
+	case reflect.Slice:
+		if value.IsNil() {
+			return reflect.MakeSlice(value.Type(), 0, 0).Interface()
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			return reflect.MakeMap(value.Type()).Interface()
+		}
+	}
+	return v
+}
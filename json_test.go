@@ -0,0 +1,154 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type createUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+type createdUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (createdUser) StatusCode() int { return http.StatusCreated }
+
+func TestJSON(t *testing.T) {
+	router := NewRouter()
+	router.Post("/users", JSON(func(ctx context.Context, in createUser) (createdUser, error) {
+		if in.Name == "taken" {
+			return createdUser{}, NewError(http.StatusConflict, "name taken")
+		}
+		return createdUser{ID: "1", Name: in.Name}, nil
+	}))
+	router.With(BodyLimit(16)).Put("/small", JSON(func(ctx context.Context, in createUser) (*createdUser, error) {
+		return nil, nil
+	}))
+	router.Get("/users", JSON(func(ctx context.Context, _ struct{}) ([]string, error) {
+		return []string{"ada"}, nil
+	}))
+	router.Get("/none", JSON(func(ctx context.Context, _ struct{}) ([]string, error) {
+		return nil, nil
+	}))
+	router.Get("/labels", JSON(func(ctx context.Context, _ struct{}) (map[string]string, error) {
+		return nil, nil
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"decodes and encodes", "POST", "/users", "application/json", `{"name": "ada", "age": 36}`, 201, `{"id":"1","name":"ada"}` + "\n"},
+		{"accepts +json types", "POST", "/users", "application/merge-patch+json; charset=utf-8", `{"name": "ada"}`, 201, `{"id":"1","name":"ada"}` + "\n"},
+		{"empty body", "GET", "/users", "", "", 200, `["ada"]` + "\n"},
+		{"nil response", "PUT", "/small", "application/json", `{}`, 204, ""},
+		{"nil slice", "GET", "/none", "", "", 200, "[]\n"},
+		{"nil map", "GET", "/labels", "", "", 200, "{}\n"},
+		{"returned errors", "POST", "/users", "application/json", `{"name": "taken"}`, 409, ""},
+		{"malformed", "POST", "/users", "application/json", `{"name": `, 400, ""},
+		{"wrong type", "POST", "/users", "application/json", `{"age": "old"}`, 400, ""},
+		{"unknown fields", "POST", "/users", "application/json", `{"nmae": "ada"}`, 400, ""},
+		{"trailing data", "POST", "/users", "application/json", `{"name": "ada"} {}`, 400, ""},
+		{"content type", "POST", "/users", "text/plain", `{"name": "ada"}`, 415, ""},
+		{"form content type", "POST", "/users", "application/x-www-form-urlencoded", "name=ada", 415, ""},
+		{"route body limit", "PUT", "/small", "application/json", `{"name": "much too long"}`, 413, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedBody != "" {
+				if w.Body.String() != tt.expectedBody {
+					t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+				}
+				if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("Expected application/json, got %q", contentType)
+				}
+			}
+		})
+	}
+
+	t.Run("describes decode failures", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"nmae": "ada"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var problem Problem
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		if problem.Detail != `unknown field "nmae"` {
+			t.Errorf("Expected the unknown field in the detail, got %q", problem.Detail)
+		}
+	})
+
+	t.Run("encoding failures are 500s", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/bad", JSON(func(ctx context.Context, _ struct{}) (any, error) {
+			return map[string]any{"fn": func() {}}, nil
+		}))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/bad", nil))
+
+		if w.Code != 500 {
+			t.Errorf("Expected status 500, got %d", w.Code)
+		}
+	})
+}
+
+type upsertResult struct {
+	Created bool `json:"created"`
+}
+
+func (u *upsertResult) StatusCode() int {
+	if u.Created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+func TestHandleJSON(t *testing.T) {
+	router := NewRouter()
+	// Registering must not call StatusCode, which would dereference nil.
+	HandleJSON(router, http.MethodPut, "/items/{id}", func(ctx context.Context, in struct {
+		ID string `path:"id"`
+	}) (*upsertResult, error) {
+		return &upsertResult{Created: in.ID == "new"}, nil
+	})
+
+	route := router.Routes()[0]
+	if route.Request == nil || route.Response != reflect.TypeFor[*upsertResult]() || route.ResponseStatus != http.StatusOK {
+		t.Errorf("Expected the types to be documented, got %+v", route)
+	}
+
+	for id, expected := range map[string]int{"new": http.StatusCreated, "old": http.StatusOK} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/items/"+id, nil))
+		if w.Code != expected {
+			t.Errorf("Expected the status of the returned value, %d, got %d", expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// schemaViolation is a value breaking a schema keyword.
+type schemaViolation struct {
+	// path locates the value, such as "items[0].name", or "" for the root.
+	path    string
+	message string
+}
+
+// schemaValidator checks decoded JSON values against the schemas of an
+// OpenAPI document.
+type schemaValidator struct {
+	doc      *OpenAPI
+	patterns sync.Map
+}
+
+func (v *schemaValidator) validate(schema *Schema, value any) []schemaViolation {
+	var violations []schemaViolation
+	v.check(schema, value, "", &violations, 0)
+	return violations
+}
+
+// maxSchemaDepth bounds the $ref chains of recursive schemas.
+const maxSchemaDepth = 64
+
+func (v *schemaValidator) check(schema *Schema, value any, path string, violations *[]schemaViolation, depth int) {
+	if schema == nil {
+		return
+	}
+	if depth > maxSchemaDepth {
+		*violations = append(*violations, schemaViolation{path, "is nested too deeply"})
+		return
+	}
+
+	if schema.Ref != "" {
+		resolved, err := v.resolve(schema.Ref)
+		if err != nil {
+			*violations = append(*violations, schemaViolation{path, err.Error()})
+			return
+		}
+		v.check(resolved, value, path, violations, depth+1)
+		return
+	}
+
+	add := func(format string, args ...any) {
+		*violations = append(*violations, schemaViolation{path, fmt.Sprintf(format, args...)})
+	}
+
+	for _, sub := range schema.AllOf {
+		v.check(sub, value, path, violations, depth+1)
+	}
+	if len(schema.AnyOf) > 0 && v.matching(schema.AnyOf, value, path, depth) == 0 {
+		add("must match at least one of the allowed schemas")
+	}
+	if len(schema.OneOf) > 0 {
+		if matches := v.matching(schema.OneOf, value, path, depth); matches != 1 {
+			add("must match exactly one of the allowed schemas, matched %d", matches)
+		}
+	}
+	if schema.Not != nil && v.matching([]*Schema{schema.Not}, value, path, depth) == 1 {
+		add("is not allowed")
+	}
+
+	types := schema.Type
+	if schema.Nullable && len(types) > 0 {
+		types = append(SchemaTypes{"null"}, types...)
+	}
+	if len(types) > 0 && !jsonTypeMatches(value, types) {
+		add("must be %s", strings.Join(articled(types), " or "))
+		return
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			encoded := make([]string, len(schema.Enum))
+			for i, allowed := range schema.Enum {
+				b, _ := json.Marshal(allowed)
+				encoded[i] = string(b)
+			}
+			add("must be one of %s", strings.Join(encoded, ", "))
+		}
+	}
+	if schema.Const != nil && !jsonEqual(schema.Const, value) {
+		b, _ := json.Marshal(schema.Const)
+		add("must be %s", b)
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.checkString(schema, value, add)
+	case json.Number, float64:
+		n := jsonFloat(value)
+		switch {
+		case schema.Minimum != nil && n < *schema.Minimum:
+			add("must be at least %v", *schema.Minimum)
+		case schema.ExclusiveMinimum != nil && n <= *schema.ExclusiveMinimum:
+			add("must be greater than %v", *schema.ExclusiveMinimum)
+		case schema.Maximum != nil && n > *schema.Maximum:
+			add("must be at most %v", *schema.Maximum)
+		case schema.ExclusiveMaximum != nil && n >= *schema.ExclusiveMaximum:
+			add("must be less than %v", *schema.ExclusiveMaximum)
+		}
+	case []any:
+		if schema.MinItems != nil && len(value) < *schema.MinItems {
+			add("must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(value) > *schema.MaxItems {
+			add("must have at most %d items", *schema.MaxItems)
+		}
+		for i, item := range value {
+			v.check(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), violations, depth+1)
+		}
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				*violations = append(*violations, schemaViolation{joinSchemaPath(path, name), "is required"})
+			}
+		}
+		for name, property := range value {
+			propertyPath := joinSchemaPath(path, name)
+			if propertySchema, ok := schema.Properties[name]; ok {
+				v.check(propertySchema, property, propertyPath, violations, depth+1)
+				continue
+			}
+			if additional := schema.AdditionalProperties; additional != nil {
+				if additional.Not != nil && reflect.ValueOf(*additional.Not).IsZero() {
+					*violations = append(*violations, schemaViolation{propertyPath, "is not allowed"})
+					continue
+				}
+				v.check(additional, property, propertyPath, violations, depth+1)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) checkString(schema *Schema, value string, add func(string, ...any)) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		add("must be at least %d characters", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		add("must be at most %d characters", *schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		if pattern := v.pattern(schema.Pattern); pattern != nil && !pattern.MatchString(value) {
+			add("must match %s", schema.Pattern)
+		}
+	}
+
+	switch schema.Format {
+	case "email":
+		if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+			add("must be an email address")
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			add("must be a UUID")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			add("must be an RFC 3339 date-time")
+		}
+	case "date":
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			add("must be a date")
+		}
+	case "uri":
+		if u, err := url.Parse(value); err != nil || !u.IsAbs() {
+			add("must be an absolute URI")
+		}
+	}
+}
+
+// matching counts the schemas value is valid against.
+func (v *schemaValidator) matching(schemas []*Schema, value any, path string, depth int) int {
+	matches := 0
+	for _, schema := range schemas {
+		var violations []schemaViolation
+		v.check(schema, value, path, &violations, depth+1)
+		if len(violations) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func (v *schemaValidator) pattern(expr string) *regexp.Regexp {
+	if cached, ok := v.patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp)
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	v.patterns.Store(expr, pattern)
+	return pattern
+}
+
+// resolve looks up a local reference to a component schema.
+func (v *schemaValidator) resolve(ref string) (*Schema, error) {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok {
+		return nil, fmt.Errorf("has an unsupported $ref %q", ref)
+	}
+	name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+
+	if v.doc == nil || v.doc.Components == nil || v.doc.Components.Schemas[name] == nil {
+		return nil, fmt.Errorf("has an unresolvable $ref %q", ref)
+	}
+	return v.doc.Components.Schemas[name], nil
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonTypeMatches(value any, types SchemaTypes) bool {
+	for _, typ := range types {
+		switch value := value.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case json.Number, float64:
+			n := jsonFloat(value)
+			if typ == "number" || (typ == "integer" && n == float64(int64(n))) {
+				return true
+			}
+		case []any:
+			if typ == "array" {
+				return true
+			}
+		case map[string]any:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func articled(types SchemaTypes) []string {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		switch typ {
+		case "null":
+			names[i] = "null"
+		case "array", "integer", "object":
+			names[i] = "an " + typ
+		default:
+			names[i] = "a " + typ
+		}
+	}
+	return names
+}
+
+func jsonFloat(value any) float64 {
+	switch value := value.(type) {
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case float64:
+		return value
+	}
+	return 0
+}
+
+// jsonEqual compares decoded JSON values, whichever way their numbers were
+// decoded.
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		return jsonFloat(value)
+	case int:
+		return float64(value)
+	case []any:
+		normalized := make([]any, len(value))
+		for i, item := range value {
+			normalized[i] = normalizeJSON(item)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = normalizeJSON(item)
+		}
+		return normalized
+	}
+	return value
+}
+
+// coerceParameter converts the string values of a parameter to the JSON value
+// its schema describes, leaving values that do not convert as strings so the
+// schema reports them.
+func (v *schemaValidator) coerceParameter(schema *Schema, values []string) any {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < maxSchemaDepth; depth++ {
+		resolved, err := v.resolve(schema.Ref)
+		if err != nil {
+			break
+		}
+		schema = resolved
+	}
+
+	if schema != nil && schema.Type.Has("array") {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]any, len(values))
+		for i, value := range values {
+			items[i] = v.coerceParameter(schema.Items, []string{value})
+		}
+		return items
+	}
+
+	value := values[0]
+	if schema == nil {
+		return value
+	}
+	switch {
+	case schema.Type.Has("integer") || schema.Type.Has("number"):
+		if yamlFloat.MatchString(value) {
+			return json.Number(strings.TrimPrefix(value, "+"))
+		}
+	case schema.Type.Has("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,331 @@
+package simplerouter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// MirrorOptions configures the Mirror middleware.
+type MirrorOptions struct {
+	// Handler serves the shadow requests.
+	Handler http.Handler
+	// URL is an upstream shadow requests are proxied to when Handler is nil.
+	URL string
+	// SampleRate is the share of requests mirrored, from 0 to 1. Defaults to
+	// 1, every request.
+	SampleRate float64
+	// MaxConcurrent bounds the shadow requests in flight; requests whose
+	// primary response completes while it is reached are not mirrored.
+	// Defaults to 16.
+	MaxConcurrent int
+	// MaxBodySize is the largest request body buffered to be mirrored, as the
+	// primary reads it; requests with larger bodies are not mirrored. Defaults
+	// to DefaultJSONBodyLimit.
+	MaxBodySize int64
+	// Timeout bounds each shadow request. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Headers are the response headers compared between the primary and the
+	// shadow. Defaults to Content-Type.
+	Headers []string
+	// Record receives the outcome of every mirrored request. Defaults to
+	// logging those whose responses differ.
+	Record func(MirrorResult)
+
+	random func() float64
+}
+
+// MirrorResult compares the responses of a mirrored request.
+type MirrorResult struct {
+	Method string
+	Path   string
+	// RouteFromContext of the primary request, if routed.
+	Route   RouteInfo
+	Primary MirrorResponse
+	Shadow  MirrorResponse
+	// Diffs describes every difference between the responses, such as
+	// "status 200 != 500"; it is empty when they match.
+	Diffs []string
+	// Err is set when the shadow handler panicked, in which case the shadow
+	// status is 500.
+	Err error
+}
+
+// MirrorResponse summarizes a response seen by Mirror.
+type MirrorResponse struct {
+	Status int
+	Header http.Header
+	// BodyHash is the hex SHA-256 of the body.
+	BodyHash string
+	Duration time.Duration
+}
+
+// Mirror returns a middleware sending a copy of a sample of requests to a
+// shadow handler or upstream, after the primary response has been written so
+// shadowing never delays it. Shadow responses are discarded; their status,
+// compared headers and body hash are checked against the primary response
+// and passed to Record. Shadow requests carry an X-Shadow-Request header.
+//
+//	r.Use(Mirror(MirrorOptions{URL: "http://orders-v2:8080", SampleRate: 0.05}))
+func Mirror(opts MirrorOptions) func(http.Handler) http.Handler {
+	if opts.Handler == nil {
+		if opts.URL == "" {
+			panic("simplerouter: Mirror requires a Handler or URL")
+		}
+		proxy, err := NewProxy([]string{opts.URL}, ProxyOptions{Retries: -1})
+		if err != nil {
+			panic("simplerouter: Mirror: " + err.Error())
+		}
+		opts.Handler = proxy
+	}
+	if opts.SampleRate == 0 {
+		opts.SampleRate = 1
+	}
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = 16
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultJSONBodyLimit
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Headers == nil {
+		opts.Headers = []string{"Content-Type"}
+	}
+	if opts.Record == nil {
+		opts.Record = logMirrorResult
+	}
+	if opts.random == nil {
+		opts.random = rand.Float64
+	}
+
+	slots := make(chan struct{}, opts.MaxConcurrent)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.random() >= opts.SampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The shadow is cloned before the primary handler can change the
+			// request, and outlives it, so it keeps its values but not its
+			// cancellation.
+			shadow := r.Clone(context.WithoutCancel(r.Context()))
+			var body *mirrorBody
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &mirrorBody{ReadCloser: r.Body, limit: opts.MaxBodySize}
+				r.Body = body
+			}
+
+			si := toStatusInterceptor(w, r)
+			recorder := &mirrorWriter{ResponseWriter: si, hash: sha256.New()}
+			start := time.Now()
+			next.ServeHTTP(recorder, r)
+
+			primary := MirrorResponse{
+				Status:   si.Status,
+				Header:   pickHeaders(si.Header(), opts.Headers),
+				BodyHash: hex.EncodeToString(recorder.hash.Sum(nil)),
+				Duration: time.Since(start),
+			}
+			if primary.Status == 0 {
+				primary.Status = http.StatusOK
+			}
+
+			var captured []byte
+			if body != nil {
+				var ok bool
+				if captured, ok = body.finish(); !ok {
+					return
+				}
+			}
+			select {
+			case slots <- struct{}{}:
+			default:
+				return
+			}
+			go func() {
+				defer func() { <-slots }()
+				opts.Record(shadowRequest(shadow, captured, primary, &opts))
+			}()
+		})
+	}
+}
+
+// mirrorBody keeps a copy of the request body as the primary reads it, so
+// mirroring adds no latency before the primary handler runs.
+type mirrorBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	eof      bool
+	overflow bool
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.overflow {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// finish reads whatever the primary left of the body, once its response is
+// written, and reports whether the whole body fit within the limit.
+func (b *mirrorBody) finish() ([]byte, bool) {
+	if !b.eof && !b.overflow {
+		io.Copy(io.Discard, io.LimitReader(b, b.limit-int64(b.buf.Len())+1))
+	}
+	if !b.eof || b.overflow {
+		return nil, false
+	}
+	return b.buf.Bytes(), true
+}
+
+// shadowRequest serves a copy of a primary request to the shadow handler and
+// compares its response with the primary one.
+func shadowRequest(r *http.Request, body []byte, primary MirrorResponse, opts *MirrorOptions) MirrorResult {
+	ctx, cancel := context.WithTimeout(r.Context(), opts.Timeout)
+	defer cancel()
+
+	shadow := r.WithContext(ctx)
+	shadow.Body = http.NoBody
+	if body != nil {
+		shadow.Body = io.NopCloser(bytes.NewReader(body))
+		shadow.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	shadow.Header.Set("X-Shadow-Request", "1")
+
+	recorder := &shadowRecorder{header: http.Header{}, hash: sha256.New()}
+	start := time.Now()
+	// The shadow is the code on trial; its panics must not take down the
+	// process serving the primary.
+	var panicErr error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicErr = fmt.Errorf("shadow handler panicked: %v", p)
+				recorder.status = http.StatusInternalServerError
+			}
+		}()
+		opts.Handler.ServeHTTP(recorder, shadow)
+	}()
+
+	result := MirrorResult{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Primary: primary,
+		Shadow: MirrorResponse{
+			Status:   recorder.status,
+			Header:   pickHeaders(recorder.header, opts.Headers),
+			BodyHash: hex.EncodeToString(recorder.hash.Sum(nil)),
+			Duration: time.Since(start),
+		},
+		Err: panicErr,
+	}
+	if result.Shadow.Status == 0 {
+		result.Shadow.Status = http.StatusOK
+	}
+	result.Route, _ = RouteFromContext(r.Context())
+	result.Diffs = diffMirrorResponses(result.Primary, result.Shadow, opts.Headers)
+	return result
+}
+
+func diffMirrorResponses(primary, shadow MirrorResponse, headers []string) []string {
+	var diffs []string
+	if primary.Status != shadow.Status {
+		diffs = append(diffs, fmt.Sprintf("status %d != %d", primary.Status, shadow.Status))
+	}
+	for _, name := range headers {
+		if a, b := primary.Header.Get(name), shadow.Header.Get(name); a != b {
+			diffs = append(diffs, fmt.Sprintf("header %s %q != %q", name, a, b))
+		}
+	}
+	if primary.BodyHash != shadow.BodyHash {
+		diffs = append(diffs, "body differs")
+	}
+	return diffs
+}
+
+func pickHeaders(header http.Header, names []string) http.Header {
+	picked := http.Header{}
+	for _, name := range names {
+		if values := header.Values(name); len(values) > 0 {
+			picked[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return picked
+}
+
+func logMirrorResult(result MirrorResult) {
+	if result.Err != nil {
+		logger.Debug("Mirror shadow failed", "method", result.Method, "path", result.Path, "error", result.Err)
+		return
+	}
+	if len(result.Diffs) > 0 {
+		logger.Debug("Mirror mismatch", "method", result.Method, "path", result.Path, "diffs", result.Diffs)
+	}
+}
+
+// mirrorWriter hashes the primary response body as it is written.
+type mirrorWriter struct {
+	http.ResponseWriter
+	hash hash.Hash
+}
+
+func (mw *mirrorWriter) Write(p []byte) (int, error) {
+	mw.hash.Write(p)
+	return mw.ResponseWriter.Write(p)
+}
+
+func (mw *mirrorWriter) Flush() {
+	http.NewResponseController(mw.ResponseWriter).Flush()
+}
+
+func (mw *mirrorWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
+
+// shadowRecorder keeps the status, headers and body hash of a shadow
+// response and discards the rest.
+type shadowRecorder struct {
+	header http.Header
+	status int
+	hash   hash.Hash
+}
+
+func (sr *shadowRecorder) Header() http.Header {
+	return sr.header
+}
+
+func (sr *shadowRecorder) WriteHeader(code int) {
+	if sr.status == 0 && code >= 200 {
+		sr.status = code
+	}
+}
+
+func (sr *shadowRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.hash.Write(p)
+}
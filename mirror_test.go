@@ -0,0 +1,270 @@
+package simplerouter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	shadowed := make(chan *http.Request, 1)
+	var shadowBody string
+	shadow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		shadowBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":2}`)
+		shadowed <- r
+	})
+
+	results := make(chan MirrorResult, 1)
+	router := NewRouter()
+	router.Use(Mirror(MirrorOptions{Handler: shadow, Record: func(result MirrorResult) { results <- result }}))
+	router.Post("/orders", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":1,"echo":`+string(body)+`}`)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", strings.NewReader(`"widget"`)))
+	if w.Code != http.StatusCreated || w.Body.String() != `{"id":1,"echo":"widget"}` {
+		t.Fatalf("Expected the primary response, got %d %q", w.Code, w.Body.String())
+	}
+
+	received := <-shadowed
+	result := <-results
+	if received.Header.Get("X-Shadow-Request") != "1" || shadowBody != `"widget"` {
+		t.Errorf("Expected the shadow to get a marked copy of the request, got %q", shadowBody)
+	}
+	if result.Method != "POST" || result.Path != "/orders" || result.Route.Pattern != "POST /orders" {
+		t.Errorf("Expected the request to be described, got %+v", result)
+	}
+	if result.Primary.Status != http.StatusCreated || result.Shadow.Status != http.StatusCreated {
+		t.Errorf("Expected both statuses, got %d %d", result.Primary.Status, result.Shadow.Status)
+	}
+	if len(result.Diffs) != 1 || result.Diffs[0] != "body differs" {
+		t.Errorf("Expected only the bodies to differ, got %v", result.Diffs)
+	}
+}
+
+func TestMirrorDiffs(t *testing.T) {
+	results := make(chan MirrorResult, 1)
+	handler := Mirror(MirrorOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+		Record: func(result MirrorResult) { results <- result },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	result := <-results
+	expected := []string{`status 200 != 500`, `header Content-Type "text/plain" != "text/html"`}
+	if strings.Join(result.Diffs, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, result.Diffs)
+	}
+	if result.Primary.BodyHash != result.Shadow.BodyHash {
+		t.Error("Expected empty bodies to hash alike")
+	}
+}
+
+func TestMirrorShadowPanic(t *testing.T) {
+	results := make(chan MirrorResult, 16)
+	handler := Mirror(MirrorOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("candidate bug")
+		}),
+		MaxConcurrent: 1,
+		Record:        func(result MirrorResult) { results <- result },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	result := <-results
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "candidate bug") || result.Shadow.Status != http.StatusInternalServerError {
+		t.Errorf("Expected the panic to be recorded as a 500, got %+v", result)
+	}
+
+	// The slot of the failed shadow is released for the next request.
+	waitFor(t, func() bool {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		select {
+		case <-results:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	})
+}
+
+func TestMirrorURL(t *testing.T) {
+	received := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.RequestURI()
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	results := make(chan MirrorResult, 1)
+	handler := Mirror(MirrorOptions{URL: upstream.URL, Record: func(result MirrorResult) { results <- result }})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") }),
+	)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search?q=x", nil))
+
+	if uri := <-received; uri != "/search?q=x" {
+		t.Errorf("Expected the request to be mirrored upstream, got %q", uri)
+	}
+	if result := <-results; len(result.Diffs) != 0 {
+		t.Errorf("Expected the responses to match, got %v", result.Diffs)
+	}
+}
+
+func TestMirrorSampling(t *testing.T) {
+	var mirrored atomic.Int32
+	values := []float64{0.05, 0.5, 0.09, 0.95}
+	opts := MirrorOptions{
+		Handler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		SampleRate: 0.1,
+		Record:     func(MirrorResult) { mirrored.Add(1) },
+	}
+	opts.random = func() float64 {
+		value := values[0]
+		values = values[1:]
+		return value
+	}
+	handler := Mirror(opts)(http.NotFoundHandler())
+
+	for range 4 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	waitFor(t, func() bool { return mirrored.Load() == 2 })
+}
+
+func TestMirrorConcurrencyAndBodyLimit(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	handler := Mirror(MirrorOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+		}),
+		MaxConcurrent: 1,
+		MaxBodySize:   4,
+		Timeout:       time.Second,
+		Record:        func(MirrorResult) {},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("abc")))
+	waitFor(t, func() bool { return calls.Load() == 1 })
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("def")))
+	if w.Body.String() != "def" {
+		t.Errorf("Expected the primary to be served while the shadow is busy, got %q", w.Body.String())
+	}
+	close(release)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("too large")))
+	if w.Body.String() != "too large" {
+		t.Errorf("Expected the full body to reach the primary, got %q", w.Body.String())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if calls.Load() != 1 {
+		t.Errorf("Expected only the first request to be mirrored, got %d", calls.Load())
+	}
+}
+
+func TestMirrorStreamsBody(t *testing.T) {
+	results := make(chan MirrorResult, 1)
+	firstChunk := make(chan string, 1)
+	var shadowBody string
+	handler := Mirror(MirrorOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			shadowBody = string(body)
+		}),
+		Record: func(result MirrorResult) { results <- result },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 5)
+		n, _ := r.Body.Read(chunk)
+		firstChunk <- string(chunk[:n])
+	}))
+
+	body, writer := io.Pipe()
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", body))
+	writer.Write([]byte("first"))
+	select {
+	case chunk := <-firstChunk:
+		if chunk != "first" {
+			t.Errorf("Expected the primary to read the first chunk, got %q", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the primary to run before the body is complete")
+	}
+	writer.Write([]byte(" second"))
+	writer.Close()
+
+	<-results
+	if shadowBody != "first second" {
+		t.Errorf("Expected the shadow to get the whole body, got %q", shadowBody)
+	}
+}
+
+func TestMirrorSlowPrimary(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var mirrored atomic.Int32
+	handler := Mirror(MirrorOptions{
+		Handler:       http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		MaxConcurrent: 1,
+		Record:        func(MirrorResult) { mirrored.Add(1) },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-entered
+
+	// Slow primaries do not hold the shadow slots.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	waitFor(t, func() bool { return mirrored.Load() == 1 })
+
+	close(release)
+	waitFor(t, func() bool { return mirrored.Load() == 2 })
+}
+
+func TestMirrorRequiresTarget(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Mirror without a Handler or URL to panic")
+		}
+	}()
+	Mirror(MirrorOptions{})
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
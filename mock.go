@@ -0,0 +1,514 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MockOptions configures NewMockRouter.
+type MockOptions struct {
+	// BasePath is prefixed to the paths of the document. Defaults to the path
+	// of its first server URL.
+	BasePath string
+	// Validate rejects requests that do not match the document, as the
+	// OpenAPIValidation middleware does.
+	Validate bool
+}
+
+// NewMockRouter returns a Router serving a mock of every operation of an
+// OpenAPI document, for developing clients without the real service. Each
+// operation responds with its first documented success status and the
+// example of the response, or data generated from its schema when it has
+// none, in the media type the Accept header prefers.
+//
+// Clients pick other responses with a Prefer header:
+//
+//	Prefer: code=404             respond with the 404 response
+//	Prefer: example=notFound     use the named example
+//	Prefer: dynamic=true         generate data from the schema
+//
+// A code the operation does not document is answered with a problem details
+// body of that status, so any error can be simulated. Applied preferences are
+// echoed in Preference-Applied.
+func NewMockRouter(doc *OpenAPI, opts MockOptions) (router *Router, err error) {
+	if opts.BasePath == "" {
+		opts.BasePath = specBasePath(doc)
+	}
+
+	router = NewRouter()
+	if opts.Validate {
+		router.Use(OpenAPIValidation(doc, OpenAPIValidationOptions{BasePath: opts.BasePath}))
+	}
+	router.SetBasePath(opts.BasePath)
+
+	templates := make([]string, 0, len(doc.Paths))
+	for template := range doc.Paths {
+		templates = append(templates, template)
+	}
+	slices.Sort(templates)
+
+	mock := &mockResponder{doc: doc, schemas: &schemaValidator{doc: doc}}
+	for _, template := range templates {
+		item := doc.Paths[template]
+		if item == nil {
+			continue
+		}
+		path := mockPattern(template)
+
+		for _, method := range openAPIMethods {
+			operation := *item.slot(method)
+			if operation == nil {
+				continue
+			}
+			if err := mock.register(router, method, path, operation); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, template, err)
+			}
+		}
+	}
+
+	return router, nil
+}
+
+var mockWildcardSeparator = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// mockPattern converts an OpenAPI path template to a ServeMux path, turning
+// parameter names into valid wildcards and matching a trailing slash exactly.
+// Names are camel-cased, as dashes and underscores in a pattern are swapped
+// for its alias.
+func mockPattern(template string) string {
+	path := pathTemplateParam.ReplaceAllStringFunc(template, func(param string) string {
+		words := mockWildcardSeparator.Split(param[1:len(param)-1], -1)
+		name := words[0]
+		for _, word := range words[1:] {
+			if word != "" {
+				name += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+		if name == "" || (name[0] >= '0' && name[0] <= '9') {
+			name = "p" + name
+		}
+		return "{" + name + "}"
+	})
+	if strings.HasSuffix(path, "/") {
+		path += "{$}"
+	}
+	return path
+}
+
+type mockResponder struct {
+	doc     *OpenAPI
+	schemas *schemaValidator
+}
+
+func (m *mockResponder) register(router *Router, method, path string, operation *Operation) (err error) {
+	// ServeMux panics on patterns it cannot parse or that conflict.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	opts := []RouteOption{OperationID(operation.OperationID), Summary(operation.Summary), Description(operation.Description), Tags(operation.Tags...)}
+	if operation.Deprecated {
+		opts = append(opts, Deprecated())
+	}
+	router.With(opts...).Handle(method, path, func(w http.ResponseWriter, r *http.Request) {
+		m.respond(w, r, operation)
+	})
+	return nil
+}
+
+func (m *mockResponder) respond(w http.ResponseWriter, r *http.Request, operation *Operation) {
+	prefer := parsePrefer(r.Header.Values("Prefer"))
+	var applied []string
+
+	status, response := m.defaultResponse(operation)
+	if code, err := strconv.Atoi(prefer["code"]); err == nil && code >= 100 && code <= 599 {
+		status, response = code, m.responseFor(operation, code)
+		applied = append(applied, "code="+prefer["code"])
+	}
+	response = m.resolveResponse(response)
+
+	if response == nil || len(response.Content) == 0 {
+		setPreferenceApplied(w, applied)
+		if response == nil && status >= 400 {
+			NewProblem(status, "").Write(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	offers := make([]string, 0, len(response.Content))
+	for mediaType := range response.Content {
+		offers = append(offers, mediaType)
+	}
+	slices.SortFunc(offers, func(a, b string) int {
+		// Offer JSON first, the format mock clients are most likely to want.
+		if isJSONMediaType(a) != isJSONMediaType(b) {
+			if isJSONMediaType(a) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	mediaType := negotiateContentType(r.Header.Get("Accept"), offers)
+	w.Header().Add("Vary", "Accept")
+	if mediaType == "" {
+		WriteError(w, r, &notAcceptableError{offers})
+		return
+	}
+
+	content := response.Content[mediaType]
+	value, example := m.example(content, prefer["example"])
+	if example != "" {
+		applied = append(applied, "example="+example)
+	}
+	if prefer["dynamic"] == "true" || content == nil || value == nil {
+		value = newMockGenerator(m.schemas).value(contentSchema(content), 0)
+		if prefer["dynamic"] == "true" {
+			applied = append(applied, "dynamic=true")
+		}
+	}
+
+	body, err := mockBody(mediaType, value)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	setPreferenceApplied(w, applied)
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// defaultResponse returns the lowest documented success response, falling
+// back to the default response as a 200.
+func (m *mockResponder) defaultResponse(operation *Operation) (int, *Response) {
+	best, bestStatus := (*Response)(nil), 0
+	for key, response := range operation.Responses {
+		status, err := strconv.Atoi(key)
+		if err != nil && key == "2XX" {
+			status = http.StatusOK
+		} else if err != nil {
+			continue
+		}
+		if status >= 200 && status < 300 && (bestStatus == 0 || status < bestStatus) {
+			best, bestStatus = response, status
+		}
+	}
+	if best != nil {
+		return bestStatus, best
+	}
+	if response := operation.Responses["default"]; response != nil {
+		return http.StatusOK, response
+	}
+	return http.StatusNoContent, nil
+}
+
+// responseFor returns the response documented for status, its status class,
+// or the default response.
+func (m *mockResponder) responseFor(operation *Operation, status int) *Response {
+	for _, key := range []string{strconv.Itoa(status), strconv.Itoa(status/100) + "XX", "default"} {
+		if response := operation.Responses[key]; response != nil {
+			return response
+		}
+	}
+	return nil
+}
+
+func (m *mockResponder) resolveResponse(response *Response) *Response {
+	if response == nil || response.Ref == "" {
+		return response
+	}
+	name, _ := strings.CutPrefix(response.Ref, "#/components/responses/")
+	if m.doc.Components == nil {
+		return nil
+	}
+	return m.doc.Components.Responses[name]
+}
+
+// example returns the example value of content, the one named by preferred
+// if it exists, and the name of the example used.
+func (m *mockResponder) example(content *MediaType, preferred string) (any, string) {
+	if content == nil {
+		return nil, ""
+	}
+
+	if len(content.Examples) > 0 {
+		names := make([]string, 0, len(content.Examples))
+		for name := range content.Examples {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		name := names[0]
+		if _, ok := content.Examples[preferred]; ok {
+			name = preferred
+		}
+		example := content.Examples[name]
+		if example != nil && example.Ref != "" && m.doc.Components != nil {
+			ref, _ := strings.CutPrefix(example.Ref, "#/components/examples/")
+			example = m.doc.Components.Examples[ref]
+		}
+		if example != nil && example.Value != nil {
+			if name == preferred {
+				return example.Value, name
+			}
+			return example.Value, ""
+		}
+	}
+
+	if content.Example != nil {
+		return content.Example, ""
+	}
+	if schema := contentSchema(content); schema != nil {
+		if schema.Example != nil {
+			return schema.Example, ""
+		}
+		if len(schema.Examples) > 0 {
+			return schema.Examples[0], ""
+		}
+	}
+	return nil, ""
+}
+
+func contentSchema(content *MediaType) *Schema {
+	if content == nil {
+		return nil
+	}
+	return content.Schema
+}
+
+// mockBody encodes value in mediaType: JSON for JSON types, and the value
+// itself, or its JSON when it is not a string, for anything else.
+func mockBody(mediaType string, value any) ([]byte, error) {
+	if s, ok := value.(string); ok && !isJSONMediaType(mediaType) {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
+}
+
+// parsePrefer reads the preferences of Prefer headers (RFC 7240) into a map.
+func parsePrefer(values []string) map[string]string {
+	prefer := map[string]string{}
+	for _, value := range values {
+		for _, preference := range strings.Split(value, ",") {
+			// Parameters after a semicolon qualify a preference; the mock has
+			// no use for them.
+			preference, _, _ = strings.Cut(preference, ";")
+			name, token, _ := strings.Cut(strings.TrimSpace(preference), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, seen := prefer[name]; name != "" && !seen {
+				prefer[name] = strings.Trim(strings.TrimSpace(token), `"`)
+			}
+		}
+	}
+	return prefer
+}
+
+func setPreferenceApplied(w http.ResponseWriter, applied []string) {
+	if len(applied) > 0 {
+		w.Header().Set("Preference-Applied", strings.Join(applied, ", "))
+	}
+}
+
+// mockGenerator builds fake values matching a schema. The values are
+// deterministic, so mocked responses are stable between requests.
+type mockGenerator struct {
+	schemas *schemaValidator
+	// expanding holds the references being expanded on the current path, so
+	// recursive schemas stop repeating beyond maxMockDepth even through
+	// required properties.
+	expanding map[string]bool
+}
+
+func newMockGenerator(schemas *schemaValidator) *mockGenerator {
+	return &mockGenerator{schemas: schemas, expanding: map[string]bool{}}
+}
+
+// maxMockDepth bounds the nesting of generated values; optional properties
+// and array items are left out beyond it, and recursive references become
+// null.
+const maxMockDepth = 8
+
+// cut reports whether schema is a recursive reference too deep to expand.
+func (g *mockGenerator) cut(schema *Schema, depth int) bool {
+	return schema != nil && schema.Ref != "" && depth > maxMockDepth && g.expanding[schema.Ref]
+}
+
+func (g *mockGenerator) value(schema *Schema, depth int) any {
+	if schema == nil {
+		return map[string]any{}
+	}
+	if schema.Ref != "" {
+		resolved, err := g.schemas.resolve(schema.Ref)
+		if err != nil || depth > maxSchemaDepth || g.cut(schema, depth) {
+			return nil
+		}
+		g.expanding[schema.Ref] = true
+		defer delete(g.expanding, schema.Ref)
+		return g.value(resolved, depth+1)
+	}
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case len(schema.Examples) > 0:
+		return schema.Examples[0]
+	case schema.Default != nil:
+		return schema.Default
+	case schema.Const != nil:
+		return schema.Const
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		return g.allOf(schema, depth)
+	case len(schema.OneOf) > 0:
+		return g.value(schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return g.value(schema.AnyOf[0], depth+1)
+	}
+
+	typ := ""
+	for _, candidate := range schema.Type {
+		if candidate != "null" {
+			typ = candidate
+			break
+		}
+	}
+	if typ == "" && len(schema.Properties) > 0 {
+		typ = "object"
+	}
+
+	switch typ {
+	case "object":
+		return g.object(schema, depth)
+	case "array":
+		count := 1
+		if schema.MinItems != nil && *schema.MinItems > count {
+			count = *schema.MinItems
+		}
+		if (depth > maxMockDepth || g.cut(schema.Items, depth+1)) && (schema.MinItems == nil || *schema.MinItems == 0) {
+			count = 0
+		}
+		items := make([]any, count)
+		for i := range items {
+			items[i] = g.value(schema.Items, depth+1)
+		}
+		return items
+	case "string":
+		return mockString(schema)
+	case "integer", "number":
+		return mockNumber(schema, typ == "integer")
+	case "boolean":
+		return true
+	case "null":
+		return nil
+	}
+	return map[string]any{}
+}
+
+func (g *mockGenerator) object(schema *Schema, depth int) map[string]any {
+	object := map[string]any{}
+	for name, property := range schema.Properties {
+		required := slices.Contains(schema.Required, name)
+		if !required && (depth > maxMockDepth || g.cut(property, depth+1)) {
+			continue
+		}
+		object[name] = g.value(property, depth+1)
+	}
+	return object
+}
+
+// allOf merges the values of the subschemas, which for objects combines
+// their properties.
+func (g *mockGenerator) allOf(schema *Schema, depth int) any {
+	var merged any
+	for _, sub := range schema.AllOf {
+		value := g.value(sub, depth+1)
+		object, isObject := value.(map[string]any)
+		into, intoObject := merged.(map[string]any)
+		if isObject && intoObject {
+			for name, property := range object {
+				into[name] = property
+			}
+			continue
+		}
+		merged = value
+	}
+	if len(schema.Properties) > 0 {
+		into, ok := merged.(map[string]any)
+		if !ok {
+			into = map[string]any{}
+		}
+		for name, property := range g.object(schema, depth) {
+			into[name] = property
+		}
+		merged = into
+	}
+	return merged
+}
+
+func mockString(schema *Schema) string {
+	var value string
+	switch schema.Format {
+	case "date-time":
+		value = "2024-01-01T12:00:00Z"
+	case "date":
+		value = "2024-01-01"
+	case "time":
+		value = "12:00:00"
+	case "email":
+		value = "user@example.com"
+	case "uuid":
+		value = "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		value = "https://example.com"
+	case "hostname":
+		value = "example.com"
+	case "ipv4":
+		value = "192.0.2.1"
+	case "ipv6":
+		value = "2001:db8::1"
+	case "byte":
+		value = "c3RyaW5n"
+	default:
+		value = "string"
+	}
+
+	if schema.MinLength != nil {
+		for len(value) < *schema.MinLength {
+			value += "x"
+		}
+	}
+	if schema.MaxLength != nil && len(value) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+	return value
+}
+
+func mockNumber(schema *Schema, integer bool) any {
+	value := 0.0
+	switch {
+	case schema.Minimum != nil:
+		value = *schema.Minimum
+	case schema.ExclusiveMinimum != nil:
+		value = *schema.ExclusiveMinimum + 1
+	case schema.Maximum != nil && *schema.Maximum < 0:
+		value = *schema.Maximum
+	case schema.ExclusiveMaximum != nil && *schema.ExclusiveMaximum <= 0:
+		value = *schema.ExclusiveMaximum - 1
+	}
+	if integer {
+		return int64(value)
+	}
+	return value
+}
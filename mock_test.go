@@ -0,0 +1,281 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mockSpec = `
+openapi: 3.1.0
+info:
+  title: Mock
+  version: "1"
+servers:
+  - url: /api
+paths:
+  /users:
+    get:
+      operationId: listUsers
+      responses:
+        "200":
+          description: Users.
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/User'}
+            text/csv:
+              example: "id,name\n1,Ada\n"
+  /users/{user-id}:
+    get:
+      responses:
+        "200":
+          description: A user.
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/User'}
+              examples:
+                ada:
+                  value: {id: 1, name: Ada}
+                grace:
+                  value: {id: 2, name: Grace}
+        "404":
+          $ref: '#/components/responses/NotFound'
+    delete:
+      responses:
+        "204":
+          description: Deleted.
+components:
+  responses:
+    NotFound:
+      description: No such user.
+      content:
+        application/problem+json:
+          example: {title: Not Found, status: 404}
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer, minimum: 1}
+        name: {type: string, minLength: 2}
+        email: {type: string, format: email}
+        role: {type: string, enum: [admin, member]}
+        manager: {$ref: '#/components/schemas/User'}
+`
+
+func mockRouter(t *testing.T, opts MockOptions) *Router {
+	t.Helper()
+	doc, err := ParseOpenAPI([]byte(mockSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewMockRouter(doc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestNewMockRouter(t *testing.T) {
+	router := mockRouter(t, MockOptions{Validate: true})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		header      http.Header
+		status      int
+		contentType string
+		body        string
+		applied     string
+	}{
+		{
+			name:   "first named example",
+			method: "GET", target: "/api/users/1",
+			status: http.StatusOK, contentType: "application/json",
+			body: `{"id":1,"name":"Ada"}`,
+		},
+		{
+			name:   "preferred example",
+			method: "GET", target: "/api/users/2",
+			header: http.Header{"Prefer": {"example=grace"}},
+			status: http.StatusOK, contentType: "application/json",
+			body:    `{"id":2,"name":"Grace"}`,
+			applied: "example=grace",
+		},
+		{
+			name:   "preferred code",
+			method: "GET", target: "/api/users/3",
+			header: http.Header{"Prefer": {"code=404"}},
+			status: http.StatusNotFound, contentType: "application/problem+json",
+			body:    `{"status":404,"title":"Not Found"}`,
+			applied: "code=404",
+		},
+		{
+			name:   "undocumented code",
+			method: "DELETE", target: "/api/users/3",
+			header: http.Header{"Prefer": {"respond-async, code=503"}},
+			status: http.StatusServiceUnavailable, contentType: "application/problem+json",
+			body:    `{"instance":"/api/users/3","status":503,"title":"Service Unavailable","type":"about:blank"}`,
+			applied: "code=503",
+		},
+		{
+			name:   "no content",
+			method: "DELETE", target: "/api/users/3",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "negotiated example",
+			method: "GET", target: "/api/users",
+			header: http.Header{"Accept": {"text/csv"}},
+			status: http.StatusOK, contentType: "text/csv",
+			body: "id,name\n1,Ada",
+		},
+		{
+			name:   "not acceptable",
+			method: "GET", target: "/api/users",
+			header: http.Header{"Accept": {"application/xml"}},
+			status: http.StatusNotAcceptable, contentType: "application/problem+json",
+		},
+		{
+			name:   "validated",
+			method: "POST", target: "/api/users",
+			status: http.StatusMethodNotAllowed, contentType: "application/problem+json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, nil)
+			for name, values := range test.header {
+				r.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", test.contentType, contentType)
+			}
+			if test.body != "" && strings.TrimSpace(w.Body.String()) != test.body {
+				t.Errorf("Expected body %s, got %s", test.body, w.Body.String())
+			}
+			if applied := w.Header().Get("Preference-Applied"); applied != test.applied {
+				t.Errorf("Expected Preference-Applied %q, got %q", test.applied, applied)
+			}
+		})
+	}
+}
+
+func TestMockGeneratedData(t *testing.T) {
+	router := mockRouter(t, MockOptions{BasePath: "/mock"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mock/users", nil))
+
+	var users []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatalf("Expected a JSON array, got %q", w.Body.String())
+	}
+	if len(users) != 1 {
+		t.Fatalf("Expected one generated user, got %v", users)
+	}
+
+	user := users[0]
+	for name, expected := range map[string]any{"id": 1.0, "name": "string", "email": "user@example.com", "role": "admin"} {
+		if !reflect.DeepEqual(user[name], expected) {
+			t.Errorf("Expected %s to be %v, got %v", name, expected, user[name])
+		}
+	}
+
+	// The recursive manager chain ends once optional properties are left out.
+	depth := 0
+	for manager, ok := user["manager"].(map[string]any); ok; manager, ok = manager["manager"].(map[string]any) {
+		depth++
+	}
+	if depth == 0 || depth > maxMockDepth {
+		t.Errorf("Expected a bounded chain of managers, got %d", depth)
+	}
+
+	doc, _ := ParseOpenAPI([]byte(mockSpec))
+	v := &schemaValidator{doc: doc}
+	var value any
+	json.Unmarshal(w.Body.Bytes(), &value)
+	if violations := v.validate(doc.Paths["/users"].Get.Responses["200"].Content["application/json"].Schema, value); len(violations) > 0 {
+		t.Errorf("Expected generated data to match the schema, got %v", violations)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/mock/users/1", nil)
+	r.Header.Set("Prefer", "dynamic=true")
+	router.ServeHTTP(w, r)
+	if w.Header().Get("Preference-Applied") != "dynamic=true" || strings.Contains(w.Body.String(), "Ada") {
+		t.Errorf("Expected generated data instead of the example, got %s", w.Body.String())
+	}
+}
+
+func TestMockRecursiveSchema(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(`
+openapi: 3.1.0
+info: {title: Trees, version: "1"}
+paths:
+  /tree:
+    get:
+      responses:
+        "200":
+          description: A tree.
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Tree'}
+components:
+  schemas:
+    Tree:
+      type: object
+      required: [left, right]
+      properties:
+        left: {$ref: '#/components/schemas/Tree'}
+        right: {$ref: '#/components/schemas/Tree'}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewMockRouter(doc, MockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/tree", nil))
+		done <- w
+	}()
+
+	select {
+	case w := <-done:
+		var tree map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &tree); err != nil || tree["left"] == nil {
+			t.Errorf("Expected a generated tree, got %d %s", w.Code, w.Body.String())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected recursive required properties to stop expanding")
+	}
+}
+
+func TestNewMockRouterInvalidPaths(t *testing.T) {
+	doc := &OpenAPI{OpenAPI: "3.1.0", Paths: map[string]*PathItem{
+		"/files/{name}.json": {Get: &Operation{}},
+	}}
+
+	if _, err := NewMockRouter(doc, MockOptions{}); err == nil || !strings.Contains(err.Error(), "GET /files/{name}.json") {
+		t.Errorf("Expected an error naming the operation, got %v", err)
+	}
+}
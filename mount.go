@@ -0,0 +1,102 @@
+package simplerouter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type originalPathContextKey struct{}
+
+// OriginalPath returns the path of r as the router received it, before
+// StripPrefix removed the mount path from it.
+func OriginalPath(r *http.Request) string {
+	if path, ok := r.Context().Value(originalPathContextKey{}).(string); ok {
+		return path
+	}
+	return r.URL.Path
+}
+
+// resetMountOptions undoes StripPrefix, TrustForwardedPrefix, MountMethods and
+// MountExact.
+func resetMountOptions(route *RouteInfo) {
+	route.StripPrefix = false
+	route.TrustForwardedPrefix = false
+	route.MountMethods = nil
+	route.MountExact = false
+}
+
+// stripMountPrefix returns a handler serving h with the path matched by the
+// mount pattern removed from requests. The prefix is appended to the
+// X-Forwarded-Prefix of an enclosing mount, or of the client if trusted, and
+// replaces it otherwise.
+func stripMountPrefix(pattern string, trust bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stripped, prefix := stripRequest(r, pattern)
+
+		_, nested := r.Context().Value(originalPathContextKey{}).(string)
+		if trust || nested {
+			prefix = strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/") + prefix
+		}
+		stripped.Header = r.Header.Clone()
+		stripped.Header.Set("X-Forwarded-Prefix", prefix)
+
+		if !nested {
+			stripped = stripped.WithContext(context.WithValue(stripped.Context(), originalPathContextKey{}, r.URL.Path))
+		}
+
+		h.ServeHTTP(w, stripped)
+	})
+}
+
+// stripRequest returns a shallow copy of r without the leading path segments
+// matched by a mount pattern, which may contain wildcards, and the prefix it
+// removed. The segments are counted on the escaped path, as ServeMux matches
+// them.
+func stripRequest(r *http.Request, pattern string) (*http.Request, string) {
+	_, pattern = splitPattern(pattern)
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "{$}"), "/")
+	segments := strings.Count(pattern, "/")
+
+	escaped := r.URL.EscapedPath()
+	end := len(escaped)
+	for i, seen := 1, 0; i < len(escaped); i++ {
+		if escaped[i] == '/' {
+			if seen++; seen == segments {
+				end = i
+				break
+			}
+		}
+	}
+	if segments == 0 {
+		end = 0
+	}
+
+	rawPrefix, rest := escaped[:end], escaped[end:]
+	if rest == "" {
+		rest = "/"
+	}
+	prefix, err := url.PathUnescape(rawPrefix)
+	if err != nil {
+		prefix = rawPrefix
+	}
+	path, err := url.PathUnescape(rest)
+	if err != nil {
+		path = rest
+	}
+
+	stripped := new(http.Request)
+	*stripped = *r
+	stripped.URL = new(url.URL)
+	*stripped.URL = *r.URL
+	stripped.URL.Path = path
+	stripped.URL.RawPath = ""
+	if r.URL.RawPath != "" {
+		stripped.URL.RawPath = rest
+	}
+	return stripped, prefix
+}
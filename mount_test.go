@@ -0,0 +1,156 @@
+package simplerouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func echoPath(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s %s %s %s", r.URL.Path, r.URL.RawPath, OriginalPath(r), r.Header.Get("X-Forwarded-Prefix"))
+}
+
+func TestMountStripPrefix(t *testing.T) {
+	router := NewRouter()
+	router.SetBasePath("/api")
+	router.With(StripPrefix()).Mount("/service", http.HandlerFunc(echoPath))
+	router.With(StripPrefix()).Mount("/orgs/{org}/files/", http.HandlerFunc(echoPath))
+	router.With(StripPrefix(), TrustForwardedPrefix()).Mount("/proxied", http.HandlerFunc(echoPath))
+	router.Route("/v1", func(r *Router) {
+		r.With(StripPrefix()).Mount("/legacy", http.HandlerFunc(echoPath))
+	})
+	inner := NewRouter()
+	inner.With(StripPrefix()).Mount("/inner", http.HandlerFunc(echoPath))
+	router.With(StripPrefix()).Mount("/outer", inner)
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		expected string
+	}{
+		{"subtree", "/api/service/users/1", "", "/users/1  /api/service/users/1 /api/service"},
+		{"mount root", "/api/service/", "", "/  /api/service/ /api/service"},
+		{"wildcards", "/api/orgs/acme/files/a.txt", "", "/a.txt  /api/orgs/acme/files/a.txt /api/orgs/acme/files"},
+		{"escaped", "/api/orgs/a%2Fb/files/c%2Fd", "", "/c/d /c%2Fd /api/orgs/a/b/files/c/d /api/orgs/a/b/files"},
+		{"route base path", "/api/v1/legacy/x", "", "/x  /api/v1/legacy/x /api/v1/legacy"},
+		{"untrusted forwarded prefix", "/api/service/x", "/evil/", "/x  /api/service/x /api/service"},
+		{"trusted forwarded prefix", "/api/proxied/x", "/edge/", "/x  /api/proxied/x /edge/api/proxied"},
+		{"nested", "/api/outer/inner/x", "/evil", "/x  /api/outer/inner/x /api/outer/inner"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.target, nil)
+			if test.header != "" {
+				r.Header.Set("X-Forwarded-Prefix", test.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Body.String() != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, w.Body.String())
+			}
+			if test.header == "" && r.Header.Get("X-Forwarded-Prefix") != "" {
+				t.Error("Expected the original request headers to be left alone")
+			}
+		})
+	}
+}
+
+func TestMountStripPrefixFileServer(t *testing.T) {
+	router := NewRouter()
+	router.SetBasePath("/api")
+	router.With(StripPrefix()).Mount("/files", http.FileServerFS(fstest.MapFS{"hello.txt": {Data: []byte("hello")}}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/files/hello.txt", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("Expected the file, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMountStripPrefixRouter(t *testing.T) {
+	service := NewRouter()
+	service.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		route, _ := RouteFromContext(r.Context())
+		fmt.Fprintf(w, "%s %s", r.PathValue("id"), route.Pattern)
+	})
+
+	router := NewRouter()
+	router.With(StripPrefix()).Mount("/service", service)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/service/users/7", nil))
+
+	if w.Body.String() != "7 GET /users/{id}" {
+		t.Errorf("Expected the mounted router to match the stripped path, got %q", w.Body.String())
+	}
+}
+
+func TestMountMethods(t *testing.T) {
+	router := NewRouter()
+	router.With(MountMethods(http.MethodGet, http.MethodPost)).Mount("/service", http.HandlerFunc(echoPath))
+
+	for method, expected := range map[string]int{
+		http.MethodGet:    http.StatusOK,
+		http.MethodHead:   http.StatusOK,
+		http.MethodPost:   http.StatusOK,
+		http.MethodDelete: http.StatusMethodNotAllowed,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/service/anything", nil))
+
+		if w.Code != expected {
+			t.Errorf("Expected %s to get %d, got %d", method, expected, w.Code)
+		}
+		if expected == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+			t.Errorf("Expected the mounted methods to be allowed, got %q", w.Header().Get("Allow"))
+		}
+	}
+
+	var methods []string
+	for _, route := range router.Routes() {
+		methods = append(methods, route.Method)
+	}
+	if len(methods) != 2 || methods[0] != http.MethodGet || methods[1] != http.MethodPost {
+		t.Errorf("Expected a route per method, got %v", methods)
+	}
+}
+
+func TestMountExact(t *testing.T) {
+	router := NewRouter()
+	router.With(MountExact(), StripPrefix()).Mount("/health", http.HandlerFunc(echoPath))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "/  /health /health" {
+		t.Errorf("Expected the exact path to be mounted, got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/health/", "/health/deep"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s not to be mounted, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestRouteIgnoresMountOptions(t *testing.T) {
+	router := NewRouter()
+	router.With(StripPrefix(), MountExact()).Route("/v1", func(r *Router) {
+		r.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users", nil))
+	if w.Body.String() != "/v1/users" {
+		t.Errorf("Expected the sub-router to see the full path, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,67 @@
+package simplerouter
+
+import (
+	"mime"
+	"strings"
+)
+
+// negotiateContentType returns the offer the Accept header prefers, or "" when
+// none is acceptable. Ties go to the earliest offer, and an empty header
+// accepts the first one.
+func negotiateContentType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		value, q := parseQuality(part)
+		if value != "" {
+			ranges = append(ranges, mediaRange{value, q})
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		offerType, _, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+
+		// The most specific matching range decides the quality of an offer.
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if s := rangeSpecificity(r.value, offerType); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// rangeSpecificity ranks how specifically a media range matches a media type:
+// 2 for an exact match, 1 for type/*, 0 for */* and -1 for no match.
+func rangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
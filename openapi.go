@@ -0,0 +1,398 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3 document.
+type OpenAPI struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Servers    []OpenAPIServer      `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIServer is a server the API is available at.
+type OpenAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path.
+type PathItem struct {
+	Summary     string       `json:"summary,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Parameters  []*Parameter `json:"parameters,omitempty"`
+	Get         *Operation   `json:"get,omitempty"`
+	Put         *Operation   `json:"put,omitempty"`
+	Post        *Operation   `json:"post,omitempty"`
+	Delete      *Operation   `json:"delete,omitempty"`
+	Options     *Operation   `json:"options,omitempty"`
+	Head        *Operation   `json:"head,omitempty"`
+	Patch       *Operation   `json:"patch,omitempty"`
+	Trace       *Operation   `json:"trace,omitempty"`
+}
+
+// slot returns the operation field of an HTTP method, or nil for methods
+// OpenAPI does not describe.
+func (p *PathItem) slot(method string) **Operation {
+	switch method {
+	case http.MethodGet:
+		return &p.Get
+	case http.MethodPut:
+		return &p.Put
+	case http.MethodPost:
+		return &p.Post
+	case http.MethodDelete:
+		return &p.Delete
+	case http.MethodOptions:
+		return &p.Options
+	case http.MethodHead:
+		return &p.Head
+	case http.MethodPatch:
+		return &p.Patch
+	case http.MethodTrace:
+		return &p.Trace
+	}
+	return nil
+}
+
+// Operations returns the operations of the path keyed by HTTP method.
+func (p *PathItem) Operations() map[string]*Operation {
+	operations := map[string]*Operation{}
+	for _, method := range openAPIMethods {
+		if operation := *p.slot(method); operation != nil {
+			operations[method] = operation
+		}
+	}
+	return operations
+}
+
+var openAPIMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+// Operation describes a single method of a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query, header or cookie parameter.
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Deprecated  bool    `json:"deprecated,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+	Example     any     `json:"example,omitempty"`
+}
+
+// RequestBody describes the body of an operation.
+type RequestBody struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the content of a body in one media type.
+type MediaType struct {
+	Schema   *Schema             `json:"schema,omitempty"`
+	Example  any                 `json:"example,omitempty"`
+	Examples map[string]*Example `json:"examples,omitempty"`
+}
+
+// Example is a named example value.
+type Example struct {
+	Ref     string `json:"$ref,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Value   any    `json:"value,omitempty"`
+}
+
+// Components holds the reusable objects of a document.
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas,omitempty"`
+	Parameters    map[string]*Parameter   `json:"parameters,omitempty"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies,omitempty"`
+	Responses     map[string]*Response    `json:"responses,omitempty"`
+	Examples      map[string]*Example     `json:"examples,omitempty"`
+}
+
+// OpenAPI generates an OpenAPI 3.1 document from the route table. Every route
+// registered with a method becomes an operation, documented with its path
+// wildcards, the request and response types of routes registered with
+// HandleJSON and HandleBind (or of the Accepts and Returns options), the
+// formats it Produces, and the OperationID, Summary, Description, Tags and
+// Deprecated options. Routes without a method, such as Any, mounted handlers
+// and dash/underscore aliases are left out. Every operation documents problem
+// details as its default response.
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+	}
+
+	generator := newSchemaGenerator()
+	operationIDs := map[string]int{}
+
+	for _, route := range r.Routes() {
+		if route.Alias || route.Method == "" || len(route.MountMethods) > 0 {
+			continue
+		}
+
+		path := openAPIPath(route.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		slot := item.slot(route.Method)
+		if slot == nil {
+			continue
+		}
+
+		operation := generator.operation(route, path)
+		if operationIDs[operation.OperationID]++; operationIDs[operation.OperationID] > 1 {
+			operation.OperationID += strconv.Itoa(operationIDs[operation.OperationID])
+		}
+		*slot = operation
+	}
+
+	generator.components["Problem"] = problemSchema()
+	doc.Components = &Components{Schemas: generator.components}
+
+	return doc
+}
+
+var (
+	wildcardPattern    = regexp.MustCompile(`\{([^}.$]+)(\.\.\.)?\}`)
+	operationIDInvalid = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// openAPIPath converts a ServeMux path to an OpenAPI path template: the host is
+// dropped, {name...} becomes {name} and {$} is removed.
+func openAPIPath(path string) string {
+	if i := strings.Index(path, "/"); i > 0 {
+		path = path[i:]
+	}
+	path = strings.ReplaceAll(path, "{$}", "")
+	return wildcardPattern.ReplaceAllString(path, "{$1}")
+}
+
+func (g *schemaGenerator) operation(route RouteInfo, path string) *Operation {
+	operation := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Deprecated:  route.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+	if operation.OperationID == "" {
+		operation.OperationID = strings.ToLower(route.Method) + strings.TrimRight(operationIDInvalid.ReplaceAllString(path, "_"), "_")
+	}
+
+	parameters := map[string]*Parameter{}
+	for _, match := range wildcardPattern.FindAllStringSubmatch(path, -1) {
+		parameter := &Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: SchemaTypes{"string"}}}
+		parameters["path:"+match[1]] = parameter
+		operation.Parameters = append(operation.Parameters, parameter)
+	}
+
+	if route.Request != nil {
+		g.requestParameters(operation, parameters, route.Request)
+		operation.RequestBody = g.requestBody(route.Request)
+	}
+
+	if route.Response != nil {
+		status := route.ResponseStatus
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		mediaTypes := route.Produces
+		if len(mediaTypes) == 0 {
+			mediaTypes = []string{"application/json"}
+		}
+
+		response := &Response{Description: http.StatusText(status), Content: map[string]*MediaType{}}
+		for _, mediaType := range mediaTypes {
+			response.Content[mediaType] = &MediaType{Schema: g.schema(route.Response)}
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+	} else {
+		operation.Responses["2XX"] = &Response{Description: "Successful response"}
+	}
+
+	operation.Responses["default"] = &Response{
+		Description: "Problem details",
+		Content: map[string]*MediaType{
+			"application/problem+json": {Schema: &Schema{Ref: "#/components/schemas/Problem"}},
+		},
+	}
+
+	return operation
+}
+
+// requestParameters documents the fields of a request type bound from the
+// path, query string, headers and cookies.
+func (g *schemaGenerator) requestParameters(operation *Operation, parameters map[string]*Parameter, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			g.requestParameters(operation, parameters, field.Type)
+			continue
+		}
+
+		in, name := validateFieldName(field)
+		if in == "body" || in == "form" {
+			continue
+		}
+
+		schema := g.schema(field.Type)
+		if schema.Type.Has("null") {
+			schema.Type = slices.DeleteFunc(schema.Type, func(t string) bool { return t == "null" })
+		}
+		if fallback, ok := field.Tag.Lookup("default"); ok {
+			schema.Default = fallback
+		}
+
+		rules, _ := parseRules(field)
+		required := applySchemaRules(schema, rules) || in == "path"
+
+		if existing, ok := parameters[in+":"+name]; ok {
+			existing.Schema, existing.Required = schema, required
+			continue
+		}
+
+		parameter := &Parameter{Name: name, In: in, Required: required, Schema: schema}
+		parameters[in+":"+name] = parameter
+		operation.Parameters = append(operation.Parameters, parameter)
+	}
+}
+
+// requestBody documents the JSON and form fields of a request type.
+func (g *schemaGenerator) requestBody(t reflect.Type) *RequestBody {
+	structType := t
+	for structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return &RequestBody{Content: map[string]*MediaType{"application/json": {Schema: g.schema(t)}}}
+	}
+
+	plan, err := planFor(structType)
+	if err != nil {
+		return nil
+	}
+
+	body := &RequestBody{Content: map[string]*MediaType{}}
+	if plan.body {
+		body.Content["application/json"] = &MediaType{Schema: g.schema(t)}
+	}
+
+	form := &Schema{Type: SchemaTypes{"object"}, Properties: map[string]*Schema{}}
+	for _, field := range plan.fields {
+		if field.source != "form" {
+			continue
+		}
+		structField := structType.FieldByIndex(field.index)
+		property := g.schema(structField.Type)
+		if rules, err := parseRules(structField); err == nil && applySchemaRules(property, rules) {
+			form.Required = append(form.Required, field.name)
+		}
+		form.Properties[field.name] = property
+	}
+	if len(form.Properties) > 0 {
+		body.Content["application/x-www-form-urlencoded"] = &MediaType{Schema: form}
+		body.Content["multipart/form-data"] = &MediaType{Schema: form}
+	}
+
+	if len(body.Content) == 0 {
+		return nil
+	}
+	return body
+}
+
+func problemSchema() *Schema {
+	return &Schema{
+		Type: SchemaTypes{"object"},
+		Properties: map[string]*Schema{
+			"type":     {Type: SchemaTypes{"string"}, Format: "uri-reference"},
+			"title":    {Type: SchemaTypes{"string"}},
+			"status":   {Type: SchemaTypes{"integer"}},
+			"detail":   {Type: SchemaTypes{"string"}},
+			"instance": {Type: SchemaTypes{"string"}, Format: "uri-reference"},
+		},
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document of the router, generated on each
+// request so it covers routes registered later. It responds with YAML when
+// the request path ends in .yaml or .yml, or the Accept header prefers
+// application/yaml, and with JSON otherwise:
+//
+//	r.Get("/openapi.json", r.OpenAPIHandler(info).ServeHTTP)
+//	r.Get("/openapi.yaml", r.OpenAPIHandler(info).ServeHTTP)
+func (r *Router) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := json.MarshalIndent(r.OpenAPI(info), "", "  ")
+		if err != nil {
+			WriteError(w, req, err)
+			return
+		}
+
+		contentType := "application/json"
+		if strings.HasSuffix(req.URL.Path, ".yaml") || strings.HasSuffix(req.URL.Path, ".yml") ||
+			negotiateContentType(req.Header.Get("Accept"), []string{"application/json", "application/yaml"}) == "application/yaml" {
+			if body, err = jsonToYAML(body); err != nil {
+				WriteError(w, req, err)
+				return
+			}
+			contentType = "application/yaml"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,197 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type apiUser struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name" validate:"required,max=64"`
+	Email   string    `json:"email,omitempty" validate:"email"`
+	Created time.Time `json:"created"`
+	Manager *apiUser  `json:"manager,omitempty"`
+}
+
+type apiCreateUser struct {
+	Org    string `path:"org" json:"-"`
+	DryRun bool   `query:"dry_run" json:"-"`
+	Name   string `json:"name" validate:"required"`
+	Role   string `json:"role" validate:"oneof=admin member"`
+}
+
+type apiCreated struct {
+	apiUser
+}
+
+func (apiCreated) StatusCode() int { return http.StatusCreated }
+
+func openAPIRouter() *Router {
+	router := NewRouter()
+	router.SetBasePath("/api")
+	router.Route("/orgs/{org}", func(r *Router) {
+		HandleJSON(r.With(Summary("Create a user"), Tags("users"), Returns[apiCreated](http.StatusCreated)), http.MethodPost, "/users", func(ctx context.Context, in apiCreateUser) (apiCreated, error) {
+			return apiCreated{}, nil
+		})
+		r.With(Returns[[]apiUser](http.StatusOK), Produces("application/json", "text/csv"), OperationID("listUsers")).Get("/users", func(w http.ResponseWriter, r *http.Request) {})
+		r.Get("/files/{path...}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	router.Any("/legacy", func(w http.ResponseWriter, r *http.Request) {})
+	return router
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := openAPIRouter().OpenAPI(OpenAPIInfo{Title: "Users", Version: "1.0.0"})
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Users" {
+		t.Errorf("Expected an OpenAPI 3.1 document, got %q %+v", doc.OpenAPI, doc.Info)
+	}
+
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	expectedPaths := []string{"/api/orgs/{org}/files/{path}", "/api/orgs/{org}/users"}
+	if len(paths) != 2 || doc.Paths[expectedPaths[0]] == nil || doc.Paths[expectedPaths[1]] == nil {
+		t.Fatalf("Expected paths %v, got %v", expectedPaths, paths)
+	}
+
+	t.Run("typed handler", func(t *testing.T) {
+		create := doc.Paths["/api/orgs/{org}/users"].Post
+		if create == nil {
+			t.Fatal("Expected a post operation")
+		}
+
+		if create.Summary != "Create a user" || !reflect.DeepEqual(create.Tags, []string{"users"}) {
+			t.Errorf("Expected the route metadata, got %q %v", create.Summary, create.Tags)
+		}
+
+		if create.OperationID != "post_api_orgs_org_users" {
+			t.Errorf("Expected a generated operationId, got %q", create.OperationID)
+		}
+
+		var parameters []string
+		for _, parameter := range create.Parameters {
+			parameters = append(parameters, parameter.In+":"+parameter.Name)
+		}
+		if !reflect.DeepEqual(parameters, []string{"path:org", "query:dry_run"}) {
+			t.Errorf("Expected the path and query parameters, got %v", parameters)
+		}
+		if !create.Parameters[1].Schema.Type.Has("boolean") {
+			t.Errorf("Expected a boolean query parameter, got %+v", create.Parameters[1].Schema)
+		}
+
+		body := create.RequestBody.Content["application/json"]
+		if body == nil || body.Schema.Ref != "#/components/schemas/apiCreateUser" {
+			t.Fatalf("Expected a JSON request body, got %+v", create.RequestBody)
+		}
+
+		response := create.Responses["201"]
+		if response == nil || response.Content["application/json"].Schema.Ref != "#/components/schemas/apiCreated" {
+			t.Errorf("Expected a 201 response, got %+v", create.Responses)
+		}
+
+		if create.Responses["default"].Content["application/problem+json"] == nil {
+			t.Error("Expected a problem details default response")
+		}
+	})
+
+	t.Run("documented handler", func(t *testing.T) {
+		list := doc.Paths["/api/orgs/{org}/users"].Get
+		if list.OperationID != "listUsers" {
+			t.Errorf("Expected operationId listUsers, got %q", list.OperationID)
+		}
+
+		response := list.Responses["200"]
+		if response == nil || len(response.Content) != 2 {
+			t.Fatalf("Expected a 200 response in two formats, got %+v", list.Responses)
+		}
+
+		schema := response.Content["text/csv"].Schema
+		if !schema.Type.Has("array") || schema.Items.Ref != "#/components/schemas/apiUser" {
+			t.Errorf("Expected an array of users, got %+v", schema)
+		}
+	})
+
+	t.Run("schemas", func(t *testing.T) {
+		schemas := doc.Components.Schemas
+
+		create := schemas["apiCreateUser"]
+		if _, ok := create.Properties["Org"]; ok || len(create.Properties) != 2 {
+			t.Errorf("Expected only the body fields, got %v", create.Properties)
+		}
+		if !reflect.DeepEqual(create.Required, []string{"name"}) {
+			t.Errorf("Expected name to be required, got %v", create.Required)
+		}
+		if !reflect.DeepEqual(create.Properties["role"].Enum, []any{"admin", "member"}) {
+			t.Errorf("Expected the oneof rule as an enum, got %v", create.Properties["role"].Enum)
+		}
+
+		user := schemas["apiUser"]
+		if user.Properties["created"].Format != "date-time" {
+			t.Errorf("Expected a date-time, got %+v", user.Properties["created"])
+		}
+		if user.Properties["manager"].Ref != "#/components/schemas/apiUser" {
+			t.Errorf("Expected a recursive reference, got %+v", user.Properties["manager"])
+		}
+		if *user.Properties["name"].MaxLength != 64 || user.Properties["email"].Format != "email" {
+			t.Errorf("Expected the validation rules, got %+v %+v", user.Properties["name"], user.Properties["email"])
+		}
+
+		if created := schemas["apiCreated"]; created.Properties["name"] == nil {
+			t.Errorf("Expected embedded fields to be flattened, got %v", created.Properties)
+		}
+	})
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	router := openAPIRouter()
+	handler := router.OpenAPIHandler(OpenAPIInfo{Title: "Users", Version: "1.0.0"})
+	router.Get("/openapi.json", handler.ServeHTTP)
+	router.Get("/openapi.yaml", handler.ServeHTTP)
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON, got %q", w.Header().Get("Content-Type"))
+		}
+
+		var doc OpenAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Paths["/api/openapi.json"] == nil {
+			t.Error("Expected routes registered after the handler to be documented")
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.yaml", nil))
+
+		if w.Header().Get("Content-Type") != "application/yaml" {
+			t.Errorf("Expected YAML, got %q", w.Header().Get("Content-Type"))
+		}
+
+		body := w.Body.String()
+		for _, expected := range []string{
+			"openapi: \"3.1.0\"\n",
+			"info:\n  title: Users\n  version: \"1.0.0\"\n",
+			"  \"/api/orgs/{org}/users\":\n",
+			"        - name: org\n          in: path\n          required: true\n",
+			"            application/problem+json:\n",
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected the YAML to contain %q, got:\n%s", expected, body)
+			}
+		}
+	})
+}
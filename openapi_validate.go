@@ -0,0 +1,617 @@
+package simplerouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LoadOpenAPI reads an OpenAPI 3 document in JSON or YAML from fsys, such as
+// an embed.FS or os.DirFS.
+func LoadOpenAPI(fsys fs.FS, name string) (*OpenAPI, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseOpenAPI(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return doc, nil
+}
+
+// ParseOpenAPI decodes an OpenAPI 3 document in JSON or YAML.
+func ParseOpenAPI(data []byte) (*OpenAPI, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		value, err := parseYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+	return &doc, nil
+}
+
+// OpenAPIValidationOptions configures the OpenAPIValidation middleware.
+type OpenAPIValidationOptions struct {
+	// BasePath is prefixed to the paths of the document. Defaults to the path
+	// of its first server URL.
+	BasePath string
+	// MaxBodySize limits the JSON request bodies read for validation.
+	// Defaults to DefaultJSONBodyLimit.
+	MaxBodySize int64
+	// ValidateResponses checks JSON responses against the document too, and
+	// replaces those that do not match with a 500. Responses are buffered to
+	// do so, which makes it a setting for development and tests.
+	ValidateResponses bool
+}
+
+// SpecError lists the parts of a request, or of a response, that do not match
+// the OpenAPI document.
+type SpecError struct {
+	Status int
+	Fields []FieldError
+}
+
+func (e *SpecError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s %q: %s", field.Source, field.Name, field.Message)
+	}
+	return "does not match the API specification: " + strings.Join(messages, "; ")
+}
+
+// Problem returns the response for the error, a 400 for requests and a 500
+// for responses.
+func (e *SpecError) Problem() *Problem {
+	if e.Status >= http.StatusInternalServerError {
+		return NewProblem(e.Status, "The response does not match the API specification.").With("errors", e.Fields)
+	}
+	return NewProblem(e.Status, "The request does not match the API specification.").With("errors", e.Fields)
+}
+
+// OpenAPIValidation returns a middleware that checks requests against the
+// operations of an OpenAPI 3 document before they reach the handler. Path,
+// query, header and cookie parameters and JSON request bodies are validated
+// against their schemas; mismatches are rejected with a *SpecError (400), and
+// bodies in a media type the operation does not accept with a 415. Requests
+// matching no path of the document pass through, and those matching a path
+// but none of its methods are rejected with a 405.
+//
+// The body is read for validation and restored, so handlers can still decode
+// it.
+func OpenAPIValidation(doc *OpenAPI, opts OpenAPIValidationOptions) func(http.Handler) http.Handler {
+	v := newSpecValidator(doc, opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, params, ok := v.match(r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			operation := path.operations[r.Method]
+			if operation == nil {
+				w.Header().Set("Allow", strings.Join(path.methods(), ", "))
+				writeError(w, r, http.StatusMethodNotAllowed)
+				return
+			}
+
+			if err := v.validateRequest(r, path.item, operation, params); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+
+			if !v.opts.ValidateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &specResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			if err := v.validateResponse(recorder, operation); err != nil {
+				w.Header().Del("Content-Length")
+				WriteError(w, r, err)
+				return
+			}
+			recorder.flush()
+		})
+	}
+}
+
+// specPath is a path template of the document with the regular expression
+// matching it.
+type specPath struct {
+	template   string
+	pattern    *regexp.Regexp
+	names      []string
+	literal    int
+	item       *PathItem
+	operations map[string]*Operation
+}
+
+func (p *specPath) methods() []string {
+	methods := make([]string, 0, len(p.operations))
+	for method := range p.operations {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+type specValidator struct {
+	schemas *schemaValidator
+	opts    OpenAPIValidationOptions
+	paths   []*specPath
+}
+
+var pathTemplateParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func newSpecValidator(doc *OpenAPI, opts OpenAPIValidationOptions) *specValidator {
+	if opts.BasePath == "" {
+		opts.BasePath = specBasePath(doc)
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = DefaultJSONBodyLimit
+	}
+
+	v := &specValidator{schemas: &schemaValidator{doc: doc}, opts: opts}
+	for template, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+
+		path := &specPath{template: template, item: item, operations: item.Operations()}
+		var expr strings.Builder
+		expr.WriteString("^")
+		last := 0
+		for _, loc := range pathTemplateParam.FindAllStringSubmatchIndex(template, -1) {
+			expr.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+			expr.WriteString("([^/]+)")
+			path.names = append(path.names, template[loc[2]:loc[3]])
+			path.literal += loc[0] - last
+			last = loc[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(template[last:]))
+		expr.WriteString("$")
+		path.literal += len(template) - last
+
+		path.pattern = regexp.MustCompile(expr.String())
+		v.paths = append(v.paths, path)
+	}
+
+	// Prefer concrete paths over templated ones, so /users/me wins over
+	// /users/{id}.
+	slices.SortFunc(v.paths, func(a, b *specPath) int {
+		if a.literal != b.literal {
+			return b.literal - a.literal
+		}
+		return strings.Compare(a.template, b.template)
+	})
+	return v
+}
+
+// specBasePath returns the path of the first server URL of doc.
+func specBasePath(doc *OpenAPI) string {
+	if len(doc.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(doc.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// match finds the path of the document matching a request path and the
+// values of its parameters.
+func (v *specValidator) match(requestPath string) (*specPath, map[string]string, bool) {
+	requestPath, ok := strings.CutPrefix(requestPath, v.opts.BasePath)
+	if !ok {
+		return nil, nil, false
+	}
+
+	for _, path := range v.paths {
+		values := path.pattern.FindStringSubmatch(requestPath)
+		if values == nil {
+			continue
+		}
+		params := make(map[string]string, len(path.names))
+		for i, name := range path.names {
+			params[name] = values[i+1]
+		}
+		return path, params, true
+	}
+	return nil, nil, false
+}
+
+func (v *specValidator) validateRequest(r *http.Request, item *PathItem, operation *Operation, params map[string]string) error {
+	var specErr SpecError
+	add := func(source, name, message string) {
+		specErr.Fields = append(specErr.Fields, FieldError{source, name, message})
+	}
+
+	for _, parameter := range v.parameters(item, operation) {
+		var values []string
+		switch parameter.In {
+		case "path":
+			if value, ok := params[parameter.Name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = r.URL.Query()[parameter.Name]
+		case "header":
+			values = r.Header.Values(parameter.Name)
+		case "cookie":
+			if cookie, err := r.Cookie(parameter.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+
+		if len(values) == 0 {
+			if parameter.Required || parameter.In == "path" {
+				add(parameter.In, parameter.Name, "is required")
+			}
+			continue
+		}
+
+		value := v.schemas.coerceParameter(parameter.Schema, values)
+		for _, violation := range v.schemas.validate(parameter.Schema, value) {
+			name := parameter.Name
+			if violation.path != "" && !strings.HasPrefix(violation.path, "[") {
+				name += "."
+			}
+			add(parameter.In, name+violation.path, violation.message)
+		}
+	}
+
+	if err := v.validateBody(r, operation, add); err != nil {
+		return err
+	}
+
+	if len(specErr.Fields) > 0 {
+		specErr.Status = http.StatusBadRequest
+		return &specErr
+	}
+	return nil
+}
+
+// parameters returns the parameters of an operation, including those of its
+// path it does not override, with references resolved.
+func (v *specValidator) parameters(item *PathItem, operation *Operation) []*Parameter {
+	var parameters []*Parameter
+	seen := map[[2]string]bool{}
+	for _, list := range [][]*Parameter{operation.Parameters, item.Parameters} {
+		for _, parameter := range list {
+			parameter = v.resolveParameter(parameter)
+			if parameter == nil {
+				continue
+			}
+			key := [2]string{parameter.In, strings.ToLower(parameter.Name)}
+			if !seen[key] {
+				seen[key] = true
+				parameters = append(parameters, parameter)
+			}
+		}
+	}
+	return parameters
+}
+
+func (v *specValidator) resolveParameter(parameter *Parameter) *Parameter {
+	if parameter == nil || parameter.Ref == "" {
+		return parameter
+	}
+	name, ok := strings.CutPrefix(parameter.Ref, "#/components/parameters/")
+	if doc := v.schemas.doc; !ok || doc.Components == nil {
+		return nil
+	}
+	return v.schemas.doc.Components.Parameters[name]
+}
+
+// validateBody checks the request body against the operation. Bodies the
+// document does not allow are reported through add; an error is returned
+// only when the request cannot be checked at all.
+func (v *specValidator) validateBody(r *http.Request, operation *Operation, add func(source, name, message string)) error {
+	body := operation.RequestBody
+	if body != nil && body.Ref != "" {
+		name, _ := strings.CutPrefix(body.Ref, "#/components/requestBodies/")
+		body = nil
+		if components := v.schemas.doc.Components; components != nil {
+			body = components.RequestBodies[name]
+		}
+	}
+
+	hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+	if body == nil || !hasBody {
+		if body != nil && body.Required {
+			add("body", "", "is required")
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	content, ok := body.Content[mediaType]
+	if !ok {
+		for offered, candidate := range body.Content {
+			if matchContentType(mediaType, []string{offered}) {
+				content, ok = candidate, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return NewError(http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type %q is not accepted.", mediaType))
+	}
+	if !isJSONMediaType(mediaType) || content == nil || content.Schema == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, v.opts.MaxBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > v.opts.MaxBodySize {
+		return NewError(http.StatusRequestEntityTooLarge, "")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	value, err := decodeSpecJSON(data)
+	if err != nil {
+		add("body", "", "is not valid JSON")
+		return nil
+	}
+	for _, violation := range v.schemas.validate(content.Schema, value) {
+		add("body", violation.path, violation.message)
+	}
+	return nil
+}
+
+func decodeSpecJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// validateResponse checks a buffered response against the responses of an
+// operation: the one for its status, its status class such as "2XX", or the
+// default.
+func (v *specValidator) validateResponse(recorder *specResponseWriter, operation *Operation) error {
+	status := recorder.statusCode()
+	response := operation.Responses[strconv.Itoa(status)]
+	if response == nil {
+		response = operation.Responses[strconv.Itoa(status/100)+"XX"]
+	}
+	if response == nil {
+		response = operation.Responses["default"]
+	}
+
+	fail := func(name, message string) error {
+		return &SpecError{Status: http.StatusInternalServerError, Fields: []FieldError{{"response", name, message}}}
+	}
+
+	if response == nil {
+		return fail("status", fmt.Sprintf("%d is not documented", status))
+	}
+	if response.Ref != "" {
+		name, _ := strings.CutPrefix(response.Ref, "#/components/responses/")
+		if components := v.schemas.doc.Components; components != nil && components.Responses[name] != nil {
+			response = components.Responses[name]
+		}
+	}
+	if len(response.Content) == 0 || recorder.buffer.Len() == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return fail("Content-Type", fmt.Sprintf("%q is not documented for status %d", mediaType, status))
+	}
+	if !isJSONMediaType(mediaType) || content == nil || content.Schema == nil {
+		return nil
+	}
+
+	value, err := decodeSpecJSON(recorder.buffer.Bytes())
+	if err != nil {
+		return fail("body", "is not valid JSON")
+	}
+	if violations := v.schemas.validate(content.Schema, value); len(violations) > 0 {
+		specErr := &SpecError{Status: http.StatusInternalServerError}
+		for _, violation := range violations {
+			specErr.Fields = append(specErr.Fields, FieldError{"response", violation.path, violation.message})
+		}
+		return specErr
+	}
+	return nil
+}
+
+// specResponseWriter holds back a response until it has been validated.
+type specResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buffer bytes.Buffer
+}
+
+func (sw *specResponseWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		sw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if sw.status == 0 {
+		sw.status = code
+	}
+}
+
+func (sw *specResponseWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.buffer.Write(p)
+}
+
+func (sw *specResponseWriter) statusCode() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+func (sw *specResponseWriter) flush() {
+	sw.ResponseWriter.WriteHeader(sw.statusCode())
+	sw.ResponseWriter.Write(sw.buffer.Bytes())
+}
+
+func (sw *specResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// OpenAPIDiff lists the differences between the routes of a Router and the
+// operations of an OpenAPI document, as "METHOD /path" entries.
+type OpenAPIDiff struct {
+	// Unimplemented are operations of the document no route serves.
+	Unimplemented []string
+	// Undocumented are routes the document has no operation for.
+	Undocumented []string
+}
+
+// Empty reports whether the router and the document agree.
+func (d OpenAPIDiff) Empty() bool {
+	return len(d.Unimplemented) == 0 && len(d.Undocumented) == 0
+}
+
+func (d OpenAPIDiff) String() string {
+	var b strings.Builder
+	for _, entry := range d.Unimplemented {
+		fmt.Fprintf(&b, "unimplemented: %s\n", entry)
+	}
+	for _, entry := range d.Undocumented {
+		fmt.Fprintf(&b, "undocumented: %s\n", entry)
+	}
+	return b.String()
+}
+
+// CheckOpenAPI compares the routes of the router with the operations of an
+// OpenAPI document, typically at startup once every route is registered.
+// Paths are compared with their parameter names ignored and the base path of
+// the document's first server applied. Routes without a method serve every
+// operation of their path, and Mount and other subtree patterns every
+// operation below them; neither is reported as undocumented. Aliases are
+// ignored.
+func (r *Router) CheckOpenAPI(doc *OpenAPI) OpenAPIDiff {
+	basePath := specBasePath(doc)
+
+	documented := map[string]bool{}
+	var operations []string
+	for template, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+		path := normalizeSpecPath(basePath + template)
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+			operations = append(operations, method+" "+path)
+		}
+	}
+
+	var diff OpenAPIDiff
+	var routes []RouteInfo
+	for _, route := range r.Routes() {
+		if route.Alias {
+			continue
+		}
+		routes = append(routes, route)
+
+		path := normalizeSpecPath(openAPIPath(route.Path))
+		if route.Method == "" || strings.HasSuffix(route.Path, "/") {
+			continue
+		}
+		method := route.Method
+		if method == http.MethodHead && documented[http.MethodGet+" "+path] {
+			continue
+		}
+		if !documented[method+" "+path] {
+			diff.Undocumented = append(diff.Undocumented, method+" "+path)
+		}
+	}
+
+	for _, operation := range operations {
+		method, path, _ := strings.Cut(operation, " ")
+		if !slices.ContainsFunc(routes, func(route RouteInfo) bool { return routeServes(route, method, path) }) {
+			diff.Unimplemented = append(diff.Unimplemented, operation)
+		}
+	}
+
+	slices.Sort(diff.Unimplemented)
+	slices.Sort(diff.Undocumented)
+	diff.Undocumented = slices.Compact(diff.Undocumented)
+	return diff
+}
+
+// normalizeSpecPath replaces the parameter names of a path template with {}.
+func normalizeSpecPath(path string) string {
+	return pathTemplateParam.ReplaceAllString(path, "{}")
+}
+
+// routeServes reports whether a route handles a method on a normalized path.
+func routeServes(route RouteInfo, method, path string) bool {
+	switch route.Method {
+	case "", method:
+	case http.MethodGet:
+		if method != http.MethodHead {
+			return false
+		}
+	default:
+		return false
+	}
+
+	routePath := normalizeSpecPath(openAPIPath(route.Path))
+	if routePath == path {
+		return true
+	}
+	if !strings.HasSuffix(route.Path, "/") {
+		return false
+	}
+
+	// A subtree pattern serves the paths below it; compare segments so a
+	// {} of the route matches any segment of the operation.
+	prefix := strings.Split(strings.TrimSuffix(routePath, "/"), "/")
+	segments := strings.Split(path, "/")
+	if len(segments) <= len(prefix) {
+		return false
+	}
+	for i, segment := range prefix {
+		if segment != segments[i] && segment != "{}" {
+			return false
+		}
+	}
+	return true
+}
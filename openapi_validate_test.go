@@ -0,0 +1,399 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const petstoreSpec = `
+openapi: 3.0.3
+info:
+  title: Petstore # comments are ignored
+  version: "1.0"
+servers:
+  - url: https://example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, minimum: 1, maximum: 100}
+        - $ref: '#/components/parameters/Tenant'
+      responses:
+        "200":
+          description: A list of pets.
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/NewPet'}
+      responses:
+        "201":
+          description: Created.
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: string
+          format: uuid
+    get:
+      responses:
+        2XX:
+          description: The pet.
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+    delete:
+      responses:
+        "204":
+          description: Deleted.
+  /pets/mine:
+    get:
+      responses:
+        default:
+          description: My pets.
+components:
+  parameters:
+    Tenant:
+      name: X-Tenant
+      in: header
+      required: true
+      schema:
+        type: string
+        pattern: ^[a-z]+$
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: string, format: uuid}
+        name: {type: string}
+    NewPet:
+      type: object
+      required:
+        - name
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+        tag:
+          type: string
+          nullable: true
+        age:
+          type: integer
+          minimum: 0
+          exclusiveMinimum: true
+        description:
+          type: string
+          description: >
+            Folded text
+            on two lines.
+`
+
+const petID = "0b7d2b4e-8f6a-4c1e-9a43-3d0c8f1e6a52"
+
+func TestLoadOpenAPI(t *testing.T) {
+	fsys := fstest.MapFS{
+		"api/petstore.yaml": {Data: []byte(petstoreSpec)},
+		"api/broken.json":   {Data: []byte(`{"openapi": "2.0", "paths": {}}`)},
+	}
+
+	doc, err := LoadOpenAPI(fsys, "api/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Info.Title != "Petstore" || doc.Info.Version != "1.0" || doc.Servers[0].URL != "https://example.com/v1" {
+		t.Errorf("Expected the info and servers, got %+v %+v", doc.Info, doc.Servers)
+	}
+
+	newPet := doc.Components.Schemas["NewPet"]
+	if !reflect.DeepEqual(newPet.Required, []string{"name"}) || newPet.AdditionalProperties == nil {
+		t.Errorf("Expected the NewPet schema, got %+v", newPet)
+	}
+	if age := newPet.Properties["age"]; age.ExclusiveMinimum == nil || *age.ExclusiveMinimum != 0 || age.Minimum != nil {
+		t.Errorf("Expected the boolean exclusiveMinimum to become a bound, got %+v", age)
+	}
+	if description := newPet.Properties["description"].Description; description != "Folded text on two lines.\n" {
+		t.Errorf("Expected a folded block scalar, got %q", description)
+	}
+	if limit := doc.Paths["/pets"].Get.Parameters[0].Schema; *limit.Maximum != 100 || !limit.Type.Has("integer") {
+		t.Errorf("Expected a flow mapping schema, got %+v", limit)
+	}
+
+	if _, err := LoadOpenAPI(fsys, "api/broken.json"); err == nil || !strings.Contains(err.Error(), "unsupported OpenAPI version") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+	if _, err := ParseOpenAPI([]byte("openapi: 3.1.0\n\tpaths: {}\n")); err == nil {
+		t.Error("Expected tab indentation to be rejected")
+	}
+}
+
+func petstoreHandler(t *testing.T, opts OpenAPIValidationOptions, handler http.HandlerFunc) http.Handler {
+	t.Helper()
+	doc, err := ParseOpenAPI([]byte(petstoreSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return OpenAPIValidation(doc, opts)(handler)
+}
+
+func problemErrors(t *testing.T, w *httptest.ResponseRecorder) []FieldError {
+	t.Helper()
+	var problem struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Expected a problem, got %q", w.Body.String())
+	}
+	return problem.Errors
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	var received string
+	handler := petstoreHandler(t, OpenAPIValidationOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		header   http.Header
+		body     string
+		status   int
+		expected []FieldError
+	}{
+		{
+			name:   "valid query and header",
+			method: "GET", target: "/v1/pets?limit=10",
+			header: http.Header{"X-Tenant": {"acme"}},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "invalid query and missing header",
+			method: "GET", target: "/v1/pets?limit=500",
+			status: http.StatusBadRequest,
+			expected: []FieldError{
+				{"query", "limit", "must be at most 100"},
+				{"header", "X-Tenant", "is required"},
+			},
+		},
+		{
+			name:   "unconvertible query",
+			method: "GET", target: "/v1/pets?limit=ten",
+			header: http.Header{"X-Tenant": {"ACME"}},
+			status: http.StatusBadRequest,
+			expected: []FieldError{
+				{"query", "limit", "must be an integer"},
+				{"header", "X-Tenant", "must match ^[a-z]+$"},
+			},
+		},
+		{
+			name:   "path parameter",
+			method: "GET", target: "/v1/pets/42",
+			status:   http.StatusBadRequest,
+			expected: []FieldError{{"path", "petId", "must be a UUID"}},
+		},
+		{
+			name:   "literal path preferred",
+			method: "GET", target: "/v1/pets/mine",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "valid body",
+			method: "POST", target: "/v1/pets",
+			header: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			body:   `{"name": "Rex", "tag": null, "age": 3}`,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "invalid body",
+			method: "POST", target: "/v1/pets",
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"name": "", "age": 0, "color": "red"}`,
+			status: http.StatusBadRequest,
+			expected: []FieldError{
+				{"body", "age", "must be greater than 0"},
+				{"body", "color", "is not allowed"},
+				{"body", "name", "must be at least 1 characters"},
+			},
+		},
+		{
+			name:   "missing body",
+			method: "POST", target: "/v1/pets",
+			status:   http.StatusBadRequest,
+			expected: []FieldError{{"body", "", "is required"}},
+		},
+		{
+			name:   "unsupported media type",
+			method: "POST", target: "/v1/pets",
+			header: http.Header{"Content-Type": {"text/plain"}},
+			body:   "Rex",
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "undocumented method",
+			method: "PUT", target: "/v1/pets",
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "undocumented path",
+			method: "GET", target: "/health",
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			for name, values := range test.header {
+				r.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if test.expected == nil {
+				return
+			}
+
+			errs := problemErrors(t, w)
+			// Object properties are checked in map order.
+			sortFieldErrors(errs)
+			sortFieldErrors(test.expected)
+			if !reflect.DeepEqual(errs, test.expected) {
+				t.Errorf("Expected errors %v, got %v", test.expected, errs)
+			}
+		})
+	}
+
+	t.Run("body restored", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/v1/pets", strings.NewReader(`{"name":"Rex"}`))
+		r.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if received != `{"name":"Rex"}` {
+			t.Errorf("Expected the handler to read the body, got %q", received)
+		}
+	})
+
+	t.Run("allow header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("PATCH", "/v1/pets/"+petID, nil))
+
+		if allow := w.Header().Get("Allow"); allow != "DELETE, GET" {
+			t.Errorf("Expected the documented methods, got %q", allow)
+		}
+	})
+}
+
+func sortFieldErrors(errs []FieldError) {
+	for i := range errs {
+		for j := i + 1; j < len(errs); j++ {
+			if errs[j].Source+errs[j].Name < errs[i].Source+errs[i].Name {
+				errs[i], errs[j] = errs[j], errs[i]
+			}
+		}
+	}
+}
+
+func TestOpenAPIValidationResponses(t *testing.T) {
+	var pet string
+	handler := petstoreHandler(t, OpenAPIValidationOptions{ValidateResponses: true}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, pet)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		pet = `{"id":"` + petID + `","name":"Rex"}`
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/pets/"+petID, nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != pet {
+			t.Errorf("Expected the response to pass through, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		pet = `{"id":"` + petID + `"}`
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/pets/"+petID, nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected a 500, got %d", w.Code)
+		}
+		expected := []FieldError{{"response", "name", "is required"}}
+		if errs := problemErrors(t, w); !reflect.DeepEqual(errs, expected) {
+			t.Errorf("Expected errors %v, got %v", expected, errs)
+		}
+	})
+
+	t.Run("undocumented status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/pets/"+petID, nil))
+
+		expected := []FieldError{{"response", "status", "200 is not documented"}}
+		if errs := problemErrors(t, w); w.Code != http.StatusInternalServerError || !reflect.DeepEqual(errs, expected) {
+			t.Errorf("Expected errors %v, got %d %v", expected, w.Code, errs)
+		}
+	})
+}
+
+func TestCheckOpenAPI(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(petstoreSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := NewRouter()
+	router.SetBasePath("/v1")
+	router.Get("/pets", noop)
+	router.Get("/pets/{id}", noop)
+	router.Put("/pets/{id}", noop)
+	router.Get("/pets/mine", noop)
+	router.Any("/legacy", noop)
+	router.Get("/health", noop)
+
+	diff := router.CheckOpenAPI(doc)
+	expected := OpenAPIDiff{
+		Unimplemented: []string{"DELETE /v1/pets/{}", "POST /v1/pets"},
+		Undocumented:  []string{"GET /v1/health", "PUT /v1/pets/{}"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, diff)
+	}
+	if diff.Empty() {
+		t.Error("Expected the diff not to be empty")
+	}
+
+	router.Mount("/pets/{id}/", http.NotFoundHandler())
+	router.Post("/pets", noop)
+	if diff := router.CheckOpenAPI(doc); !reflect.DeepEqual(diff.Unimplemented, []string{"DELETE /v1/pets/{}"}) {
+		t.Errorf("Expected a subtree not to serve its own path, got %v", diff.Unimplemented)
+	}
+}
@@ -0,0 +1,105 @@
+package simplerouter
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// allowedMethods computes the Allow header for an OPTIONS request that has no
+// explicit handler. It returns nil when the request should be dispatched to the
+// ServeMux as usual, either because a handler accepts OPTIONS for the path
+// (Options, Any or a mounted sub-router) or because nothing matches it.
+//
+// "OPTIONS *" lists every method registered anywhere in the route table. Note
+// that http.Server answers it on its own unless DisableGeneralOptionsHandler
+// is set.
+func (m *muxWrapper) allowedMethods(r *http.Request) []string {
+	if r.URL.Path == "*" {
+		return withImplicitMethods(m.routes.methods(), true)
+	}
+
+	if _, pattern := m.ServeMux.Handler(r); pattern != "" {
+		return nil
+	}
+
+	allow := m.routeMethods(r)
+	if len(allow) == 0 {
+		return nil
+	}
+
+	return withImplicitMethods(allow, true)
+}
+
+// routeMethods returns the methods with an explicit route matching the path of
+// r, whatever its method.
+func (m *muxWrapper) routeMethods(r *http.Request) []string {
+	var allow []string
+	for _, method := range m.routes.methods() {
+		probe := *r
+		probe.Method = method
+
+		_, pattern := m.ServeMux.Handler(&probe)
+		if patternMethod, _ := splitPattern(pattern); patternMethod == method {
+			allow = append(allow, method)
+		}
+	}
+	return allow
+}
+
+// unmatchedHandler answers requests no route matches, replacing the plain text
+// 404 and 405 responses of http.ServeMux with ones from the error handler.
+func (m *muxWrapper) unmatchedHandler(r *http.Request) http.Handler {
+	allow := m.routeMethods(r)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allow) == 0 {
+			writeError(w, r, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(withImplicitMethods(allow, !m.manualOptions), ", "))
+		writeError(w, r, http.StatusMethodNotAllowed)
+	})
+}
+
+// withImplicitMethods adds the methods answered without a dedicated
+// registration: HEAD for GET routes, and OPTIONS itself when autoOptions is
+// set.
+func withImplicitMethods(methods []string, autoOptions bool) []string {
+	methods = slices.Clone(methods)
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if autoOptions && !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+// autoOptionsHandler answers an OPTIONS request with allow, through the
+// middleware of the route registered for its path with GET, or else with the
+// first of the allowed methods that has middleware.
+func (m *muxWrapper) autoOptionsHandler(r *http.Request, allow []string) http.Handler {
+	handler := optionsHandler(allow)
+	if r.URL.Path == "*" {
+		return handler
+	}
+	for _, method := range append([]string{http.MethodGet}, allow...) {
+		probe := *r
+		probe.Method = method
+
+		if _, pattern := m.ServeMux.Handler(&probe); pattern != "" {
+			if chain := m.routes.chain(m, pattern); chain != nil {
+				return chain(handler)
+			}
+		}
+	}
+	return handler
+}
+
+func optionsHandler(allow []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,190 @@
+package simplerouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAutoOptions(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}
+
+	t.Run("answers with the methods registered for the path", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/users", noop)
+		router.Post("/users", noop)
+		router.Delete("/users/{id}", noop)
+
+		req := httptest.NewRequest("OPTIONS", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+
+		expected := "GET, HEAD, OPTIONS, POST"
+		if allow := w.Header().Get("Allow"); allow != expected {
+			t.Errorf("Expected Allow %q, got %q", expected, allow)
+		}
+	})
+
+	t.Run("runs through the route middleware", func(t *testing.T) {
+		cors := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				next.ServeHTTP(w, r)
+			})
+		}
+		router := NewRouter()
+		router.Get("/users", noop, cors)
+		router.Post("/users", noop)
+
+		req := httptest.NewRequest("OPTIONS", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("Expected the middleware of the GET route to run, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("includes dash and underscore aliases", func(t *testing.T) {
+		router := NewRouter()
+		router.Put("/user-settings", noop)
+
+		req := httptest.NewRequest("OPTIONS", "/user_settings", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		expected := "OPTIONS, PUT"
+		if allow := w.Header().Get("Allow"); allow != expected {
+			t.Errorf("Expected Allow %q, got %q", expected, allow)
+		}
+	})
+
+	t.Run("explicit Options handler wins", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/users", noop)
+		router.Options("/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte("custom"))
+		})
+
+		req := httptest.NewRequest("OPTIONS", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 || w.Body.String() != "custom" {
+			t.Errorf("Expected custom OPTIONS handler, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("works through sub-routers", func(t *testing.T) {
+		router := NewRouter()
+		router.Route("/api", func(r *Router) {
+			r.Put("/items/{id}", noop)
+		})
+
+		req := httptest.NewRequest("OPTIONS", "/api/items/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+
+		expected := "OPTIONS, PUT"
+		if allow := w.Header().Get("Allow"); allow != expected {
+			t.Errorf("Expected Allow %q, got %q", expected, allow)
+		}
+	})
+
+	t.Run("unknown paths are not found", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/users", noop)
+
+		req := httptest.NewRequest("OPTIONS", "/missing", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 404 {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("OPTIONS * lists server-wide methods", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/users", noop)
+		router.Route("/api", func(r *Router) {
+			r.Delete("/items/{id}", noop)
+		})
+
+		req := httptest.NewRequest("OPTIONS", "*", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+
+		expected := "DELETE, GET, HEAD, OPTIONS"
+		if allow := w.Header().Get("Allow"); allow != expected {
+			t.Errorf("Expected Allow %q, got %q", expected, allow)
+		}
+	})
+
+	t.Run("can be disabled", func(t *testing.T) {
+		router := NewRouter()
+		router.SetAutoOptions(false)
+		router.Get("/users", noop)
+
+		req := httptest.NewRequest("OPTIONS", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status 405, got %d", w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+			t.Errorf("Expected OPTIONS not to be advertised, got %q", allow)
+		}
+
+		router.Options("/users", noop)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/users", nil))
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+			t.Errorf("Expected a registered OPTIONS route to be advertised, got %q", allow)
+		}
+	})
+}
+
+func TestRouterRoutes(t *testing.T) {
+	router := NewRouter()
+	router.SetBasePath("/api")
+	router.Get("/user-settings", func(w http.ResponseWriter, r *http.Request) {})
+	router.Route("/v1", func(r *Router) {
+		r.Post("/items", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	expected := []RouteInfo{
+		{Method: "GET", Pattern: "GET /api/user-settings", Path: "/api/user-settings"},
+		{Method: "GET", Pattern: "GET /api/user_settings", Path: "/api/user_settings", Alias: true},
+		{Method: "POST", Pattern: "POST /api/v1/items", Path: "/api/v1/items"},
+		{Method: "", Pattern: "/api/v1/", Path: "/api/v1/"},
+	}
+
+	routes := router.Routes()
+	if len(routes) != len(expected) {
+		t.Fatalf("Expected %d routes, got %d: %v", len(expected), len(routes), routes)
+	}
+
+	for i, route := range expected {
+		if !reflect.DeepEqual(routes[i], route) {
+			t.Errorf("Expected routes[%d] = %+v, got %+v", i, route, routes[i])
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Problem is an RFC 9457 problem details object. It is the shape of every
+// error response generated by the router, and handlers can either return it
+// as an error or write it directly.
+type Problem struct {
+	// Type is a URI identifying the problem type. Defaults to "about:blank".
+	Type string
+	// Title is a short summary of the problem type. Defaults to the status
+	// text.
+	Title  string
+	Status int
+	// Detail explains this occurrence of the problem.
+	Detail string
+	// Instance identifies this occurrence. Defaults to the request path.
+	Instance string
+	// Extensions are additional members serialized alongside the standard
+	// ones.
+	Extensions map[string]any
+}
+
+// NewProblem returns a Problem with the given status and detail.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Status: status, Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.title() + ": " + p.Detail
+	}
+	return p.title()
+}
+
+// With returns a copy of the problem with an extension member set.
+func (p *Problem) With(key string, value any) *Problem {
+	copied := *p
+	copied.Extensions = make(map[string]any, len(p.Extensions)+1)
+	for k, v := range p.Extensions {
+		copied.Extensions[k] = v
+	}
+	copied.Extensions[key] = value
+	return &copied
+}
+
+func (p *Problem) title() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.Status)
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = "about:blank"
+	}
+	members["title"] = p.title()
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	fields := map[string]any{"type": &p.Type, "title": &p.Title, "status": &p.Status, "detail": &p.Detail, "instance": &p.Instance}
+	for key, raw := range members {
+		if field, ok := fields[key]; ok {
+			if err := json.Unmarshal(raw, field); err != nil {
+				return fmt.Errorf("problem member %q: %w", key, err)
+			}
+			continue
+		}
+
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = map[string]any{}
+		}
+		p.Extensions[key] = value
+	}
+	return nil
+}
+
+var problemContentTypes = []string{"application/problem+json", "application/json", "text/plain", "text/html"}
+
+// Write sends the problem as application/problem+json, or as plain text or
+// HTML when the Accept header of r prefers them.
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) {
+	problem := *p
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Instance == "" && r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	contentType := "application/problem+json"
+	if r != nil {
+		contentType = negotiateContentType(r.Header.Get("Accept"), problemContentTypes)
+	}
+
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	switch contentType {
+	case "text/plain":
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(problem.Status)
+		w.Write([]byte(problem.text()))
+	case "text/html":
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(problem.Status)
+		w.Write([]byte(problem.html()))
+	default:
+		body, err := json.Marshal(&problem)
+		if err != nil {
+			body, _ = json.Marshal(&Problem{Status: problem.Status, Instance: problem.Instance})
+		}
+		header.Set("Content-Type", "application/problem+json")
+		w.WriteHeader(problem.Status)
+		w.Write(body)
+	}
+}
+
+func (p *Problem) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s\n", p.Status, p.title())
+	if p.Detail != "" {
+		fmt.Fprintf(&b, "\n%s\n", p.Detail)
+	}
+
+	keys := make([]string, 0, len(p.Extensions))
+	for key := range p.Extensions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		encoded, _ := json.Marshal(p.Extensions[key])
+		fmt.Fprintf(&b, "%s: %s\n", key, encoded)
+	}
+	return b.String()
+}
+
+func (p *Problem) html() string {
+	title := html.EscapeString(strconv.Itoa(p.Status) + " " + p.title())
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + title + "</title></head>\n<body><h1>" + title + "</h1>\n")
+	if p.Detail != "" {
+		b.WriteString("<p>" + html.EscapeString(p.Detail) + "</p>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// problemer is implemented by errors that describe their own response, such
+// as *BindError.
+type problemer interface {
+	Problem() *Problem
+}
+
+// ProblemOf converts an error into the Problem the router responds with. A
+// *Problem in the chain is used as-is, as is the result of the Problem method
+// of an error implementing it. An *Error keeps its message as detail and its
+// code as the "code" extension, and the details of any other error are hidden
+// behind its status.
+func ProblemOf(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var described problemer
+	if errors.As(err, &described) {
+		return described.Problem()
+	}
+
+	status := StatusOf(err)
+	problem = &Problem{Status: status}
+
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		if message := httpErr.message(); message != http.StatusText(status) {
+			problem.Detail = message
+		}
+		if httpErr.Code != "" {
+			problem = problem.With("code", httpErr.Code)
+		}
+	}
+
+	return problem
+}
@@ -0,0 +1,159 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	router := NewRouter()
+	router.Get("/users/{id}", Func(func(w http.ResponseWriter, r *http.Request) error {
+		if r.PathValue("id") == "slow" {
+			return fmt.Errorf("query: %w", context.DeadlineExceeded)
+		}
+		return &Problem{
+			Type:       "https://example.com/probs/out-of-credit",
+			Title:      "You do not have enough credit.",
+			Status:     http.StatusForbidden,
+			Detail:     "Your current balance is 30, but that costs 50.",
+			Extensions: map[string]any{"balance": 30},
+		}
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expected       map[string]any
+	}{
+		{"not found", "GET", "/missing", 404, map[string]any{
+			"type": "about:blank", "title": "Not Found", "status": 404.0, "instance": "/missing",
+		}},
+		{"method not allowed", "DELETE", "/users/1", 405, map[string]any{
+			"type": "about:blank", "title": "Method Not Allowed", "status": 405.0, "instance": "/users/1",
+		}},
+		{"returned problem", "GET", "/users/1", 403, map[string]any{
+			"type":     "https://example.com/probs/out-of-credit",
+			"title":    "You do not have enough credit.",
+			"status":   403.0,
+			"detail":   "Your current balance is 30, but that costs 50.",
+			"instance": "/users/1",
+			"balance":  30.0,
+		}},
+		{"deadline exceeded", "GET", "/users/slow", 503, map[string]any{
+			"type": "about:blank", "title": "Service Unavailable", "status": 503.0, "instance": "/users/slow",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Expected application/problem+json, got %q", contentType)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON body, got %q", w.Body.String())
+			}
+
+			if len(body) != len(tt.expected) {
+				t.Errorf("Expected members %v, got %v", tt.expected, body)
+			}
+			for key, value := range tt.expected {
+				if body[key] != value {
+					t.Errorf("Expected %s %v, got %v", key, value, body[key])
+				}
+			}
+		})
+	}
+
+	t.Run("405 lists the allowed methods", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/users/1", nil))
+
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+			t.Errorf("Expected Allow %q, got %q", "GET, HEAD, OPTIONS", allow)
+		}
+	})
+}
+
+func TestProblemNegotiation(t *testing.T) {
+	router := NewRouter()
+	router.Get("/fail", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return NewProblem(http.StatusConflict, "<b>taken</b>")
+	}))
+
+	tests := []struct {
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{"", "application/problem+json", `"detail":"\u003cb\u003etaken\u003c/b\u003e"`},
+		{"application/json", "application/problem+json", `"status":409`},
+		{"text/plain", "text/plain; charset=utf-8", "409 Conflict\n\n<b>taken</b>\n"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<p>&lt;b&gt;taken&lt;/b&gt;</p>"},
+		{"text/*;q=0.5, application/*;q=0.1", "text/plain; charset=utf-8", "409 Conflict"},
+		{"image/png", "application/problem+json", `"title":"Conflict"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/fail", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("Expected status 409, got %d", w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.expectedType, contentType)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	problem := NewProblem(http.StatusBadRequest, "bad input").With("errors", []string{"name"})
+
+	encoded, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"detail":"bad input","errors":["name"],"status":400,"title":"Bad Request","type":"about:blank"}`
+	if string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	var decoded Problem
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Status != 400 || decoded.Detail != "bad input" || decoded.Type != "about:blank" {
+		t.Errorf("Expected the standard members to round trip, got %+v", decoded)
+	}
+
+	if _, ok := decoded.Extensions["errors"]; !ok || len(decoded.Extensions) != 1 {
+		t.Errorf("Expected only the errors extension, got %v", decoded.Extensions)
+	}
+}
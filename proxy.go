@@ -0,0 +1,431 @@
+package simplerouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyBalancer selects how a Proxy spreads requests over its targets.
+type ProxyBalancer int
+
+const (
+	// RoundRobin sends requests to each target in turn.
+	RoundRobin ProxyBalancer = iota
+	// LeastConnections sends requests to the target with the fewest requests
+	// in flight.
+	LeastConnections
+)
+
+// ProxyOptions configures a Proxy.
+type ProxyOptions struct {
+	// Balancer selects targets. Defaults to RoundRobin.
+	Balancer ProxyBalancer
+	// KeepPrefix forwards the full request path instead of the path below
+	// the prefix of Router.Proxy.
+	KeepPrefix bool
+	// PreserveHost forwards the Host header of the request instead of the
+	// host of the target.
+	PreserveHost bool
+	// TrustForwarded extends the X-Forwarded-* and Forwarded headers of
+	// requests instead of replacing them. Only set it behind proxies that
+	// sanitize these headers.
+	TrustForwarded bool
+	// Retries is how many other targets an idempotent request is sent to
+	// when connecting fails. Defaults to one less than the number of
+	// targets; negative values disable retries.
+	Retries int
+	// MaxFails is the number of consecutive failures, connection errors or
+	// 502, 503 and 504 responses, after which a target is taken out of
+	// rotation. Defaults to 3.
+	MaxFails int
+	// FailTimeout is how long an unhealthy target stays out of rotation
+	// before it is tried again. Defaults to 10 seconds.
+	FailTimeout time.Duration
+	// Transport performs the proxied requests. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// ModifyResponse, if set, may change or reject the responses of targets.
+	ModifyResponse func(*http.Response) error
+}
+
+// Proxy is a reverse proxy balancing requests over one or more targets.
+// Targets failing repeatedly are taken out of rotation for a while, and
+// idempotent requests that cannot reach a target are retried on another.
+type Proxy struct {
+	opts    ProxyOptions
+	targets []*proxyTarget
+	next    atomic.Uint64
+	reverse *httputil.ReverseProxy
+	// pattern is the mount pattern stripped from requests, if any.
+	pattern string
+}
+
+type proxyTarget struct {
+	url    *url.URL
+	active atomic.Int64
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+}
+
+// ProxyTarget is a snapshot of a target of a Proxy.
+type ProxyTarget struct {
+	URL      string
+	Healthy  bool
+	Active   int64
+	Failures int
+}
+
+// NewProxy returns a Proxy forwarding requests to targets, absolute URLs
+// whose paths are prefixed to the paths of requests.
+func NewProxy(targets []string, opts ProxyOptions) (*Proxy, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("proxy: no targets")
+	}
+	if opts.Retries == 0 {
+		opts.Retries = len(targets) - 1
+	}
+	if opts.MaxFails <= 0 {
+		opts.MaxFails = 3
+	}
+	if opts.FailTimeout <= 0 {
+		opts.FailTimeout = 10 * time.Second
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+
+	p := &Proxy{opts: opts}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("proxy: target %q is not an absolute URL", target)
+		}
+		p.targets = append(p.targets, &proxyTarget{url: u})
+	}
+
+	p.reverse = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &proxyTransport{proxy: p},
+		ModifyResponse: opts.ModifyResponse,
+		ErrorHandler:   p.errorHandler,
+	}
+	return p, nil
+}
+
+// Proxy forwards requests under prefix to targets, removing the prefix and
+// the base path of the router from their paths unless KeepPrefix is set:
+//
+//	r.Proxy("/billing", []string{"http://billing-1:8080", "http://billing-2:8080"}, ProxyOptions{})
+func (r *Router) Proxy(prefix string, targets []string, opts ProxyOptions, chain ...middleware) (*Proxy, error) {
+	p, err := NewProxy(targets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.KeepPrefix {
+		p.pattern = r.mux.fullPattern(strings.TrimSuffix(prefix, "/") + "/")
+	}
+	r.mount(prefix, p, chain, append(slices.Clone(r.options), resetMountOptions))
+	return p, nil
+}
+
+// Targets returns the state of the targets of the proxy.
+func (p *Proxy) Targets() []ProxyTarget {
+	now := time.Now()
+	targets := make([]ProxyTarget, len(p.targets))
+	for i, target := range p.targets {
+		target.mu.Lock()
+		targets[i] = ProxyTarget{
+			URL:      target.url.String(),
+			Healthy:  !now.Before(target.downUntil),
+			Active:   target.active.Load(),
+			Failures: target.failures,
+		}
+		target.mu.Unlock()
+	}
+	return targets
+}
+
+type proxyPrefixContextKey struct{}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.pattern != "" {
+		stripped, prefix := stripRequest(r, p.pattern)
+		r = stripped.WithContext(context.WithValue(stripped.Context(), proxyPrefixContextKey{}, prefix))
+	}
+	p.reverse.ServeHTTP(w, r)
+}
+
+// rewrite sets the forwarding headers of an outbound request. The target is
+// chosen by the transport, once per attempt.
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	in, out := pr.In, pr.Out
+	trust := p.opts.TrustForwarded
+
+	if trust {
+		out.Header["X-Forwarded-For"] = in.Header["X-Forwarded-For"]
+	}
+	pr.SetXForwarded()
+	if trust {
+		for _, name := range []string{"X-Forwarded-Host", "X-Forwarded-Proto"} {
+			if value := in.Header.Get(name); value != "" {
+				out.Header.Set(name, value)
+			}
+		}
+	}
+
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	element := "for=" + forwardedNode(in.RemoteAddr) + ";host=" + forwardedValue(in.Host) + ";proto=" + proto
+	if existing := in.Header.Get("Forwarded"); trust && existing != "" {
+		element = existing + ", " + element
+	}
+	out.Header.Set("Forwarded", element)
+
+	out.Header.Del("X-Forwarded-Prefix")
+	if prefix, _ := in.Context().Value(proxyPrefixContextKey{}).(string); prefix != "" {
+		if existing := in.Header.Get("X-Forwarded-Prefix"); trust && existing != "" {
+			prefix = strings.TrimSuffix(existing, "/") + prefix
+		}
+		out.Header.Set("X-Forwarded-Prefix", prefix)
+	}
+
+	if p.opts.PreserveHost {
+		out.Host = in.Host
+	} else {
+		out.Host = ""
+	}
+}
+
+// forwardedNode formats the client address of a request as a Forwarded
+// node, quoting IPv6 addresses as RFC 7239 requires.
+func forwardedNode(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if strings.Contains(host, ":") {
+		return `"[` + host + `]"`
+	}
+	if host == "" {
+		return "unknown"
+	}
+	return host
+}
+
+func forwardedValue(value string) string {
+	if strings.ContainsAny(value, `:[]"; ,=`) {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
+
+func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		WriteError(w, r, &Error{Status: http.StatusGatewayTimeout, Err: err})
+		return
+	}
+	WriteError(w, r, &Error{Status: http.StatusBadGateway, Err: err})
+}
+
+// pick returns the next target to try, skipping those in tried. Unhealthy
+// targets are used only when every untried target is unhealthy.
+func (p *Proxy) pick(tried map[*proxyTarget]bool) *proxyTarget {
+	now := time.Now()
+	start := int(p.next.Add(1) - 1)
+
+	var best, fallback *proxyTarget
+	for i := range p.targets {
+		target := p.targets[(start+i)%len(p.targets)]
+		if tried[target] {
+			continue
+		}
+		if !target.healthy(now) {
+			if fallback == nil {
+				fallback = target
+			}
+			continue
+		}
+		if p.opts.Balancer != LeastConnections {
+			return target
+		}
+		if best == nil || target.active.Load() < best.active.Load() {
+			best = target
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+func (t *proxyTarget) healthy(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !now.Before(t.downUntil)
+}
+
+func (t *proxyTarget) report(failed bool, opts *ProxyOptions) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !failed {
+		t.failures = 0
+		return
+	}
+	if t.failures++; t.failures >= opts.MaxFails {
+		t.downUntil = time.Now().Add(opts.FailTimeout)
+		logger.Debug("Proxy target down", "target", t.url.String(), "failures", t.failures)
+	}
+}
+
+// proxyTransport sends each attempt of a request to a target picked by the
+// proxy, retrying idempotent requests on another target when connecting
+// fails.
+type proxyTransport struct {
+	proxy *Proxy
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.proxy
+	tried := map[*proxyTarget]bool{}
+	path, rawPath, query := req.URL.Path, req.URL.RawPath, req.URL.RawQuery
+
+	for attempt := 0; ; attempt++ {
+		target := p.pick(tried)
+		tried[target] = true
+
+		outbound := req
+		if attempt > 0 {
+			outbound = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				outbound.Body = body
+			}
+		}
+		outbound.URL = proxyURL(target.url, path, rawPath, query)
+		if !p.opts.PreserveHost {
+			outbound.Host = ""
+		}
+
+		target.active.Add(1)
+		resp, err := p.opts.Transport.RoundTrip(outbound)
+
+		if err == nil {
+			target.report(resp.StatusCode == http.StatusBadGateway ||
+				resp.StatusCode == http.StatusServiceUnavailable ||
+				resp.StatusCode == http.StatusGatewayTimeout, &p.opts)
+			resp.Body = trackProxyBody(resp.Body, target)
+			return resp, nil
+		}
+		target.active.Add(-1)
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		target.report(true, &p.opts)
+		logger.Debug("Proxy attempt failed", "target", target.url.String(), "attempt", attempt+1, "error", err)
+		if attempt >= p.opts.Retries || len(tried) == len(p.targets) || !retryable(req) {
+			return nil, err
+		}
+	}
+}
+
+// proxyBody keeps a request counted as active on its target until its
+// response body is read to the end or closed, so streaming and long-polling
+// responses weigh on LeastConnections.
+type proxyBody struct {
+	io.ReadCloser
+	target *proxyTarget
+	once   sync.Once
+}
+
+func trackProxyBody(body io.ReadCloser, target *proxyTarget) io.ReadCloser {
+	tracked := &proxyBody{ReadCloser: body, target: target}
+	// Upgraded connections are written to through the body.
+	if writer, ok := body.(io.Writer); ok {
+		return struct {
+			*proxyBody
+			io.Writer
+		}{tracked, writer}
+	}
+	return tracked
+}
+
+func (b *proxyBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *proxyBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *proxyBody) done() {
+	b.once.Do(func() { b.target.active.Add(-1) })
+}
+
+// retryable reports whether a failed request may be sent again: its method
+// must be idempotent and its body, if any, replayable.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// proxyURL joins the path and query of a request to a target URL.
+func proxyURL(target *url.URL, path, rawPath, query string) *url.URL {
+	u := *target
+
+	u.Path = joinURLPath(target.Path, path)
+	if rawPath != "" || target.RawPath != "" {
+		u.RawPath = joinURLPath(target.EscapedPath(), (&url.URL{Path: path, RawPath: rawPath}).EscapedPath())
+	}
+
+	switch {
+	case target.RawQuery == "":
+		u.RawQuery = query
+	case query == "":
+		u.RawQuery = target.RawQuery
+	default:
+		u.RawQuery = target.RawQuery + "&" + query
+	}
+	return &u
+}
+
+func joinURLPath(base, path string) string {
+	switch {
+	case base == "":
+		return path
+	case path == "":
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
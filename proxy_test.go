@@ -0,0 +1,265 @@
+package simplerouter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backend starts a server answering with its name, the path and query it
+// received, and the forwarding headers.
+func backend(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.RequestURI())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// deadURL returns the URL of a server that no longer accepts connections.
+func deadURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestRouterProxy(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		io.WriteString(w, r.URL.RequestURI())
+	}))
+	defer server.Close()
+
+	router := NewRouter()
+	router.SetBasePath("/api")
+	if _, err := router.Proxy("/billing", []string{server.URL + "/internal"}, ProxyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/api/billing/invoices?page=2", nil)
+	r.RemoteAddr = "203.0.113.7:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("Forwarded", "for=198.51.100.1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Body.String() != "/internal/invoices?page=2" {
+		t.Fatalf("Expected the prefix to be rewritten, got %d %q", w.Code, w.Body.String())
+	}
+
+	for name, expected := range map[string]string{
+		"X-Forwarded-For":    "203.0.113.7",
+		"X-Forwarded-Host":   "example.com",
+		"X-Forwarded-Proto":  "http",
+		"X-Forwarded-Prefix": "/api/billing",
+		"Forwarded":          "for=203.0.113.7;host=example.com;proto=http",
+	} {
+		if value := received.Header.Get(name); value != expected {
+			t.Errorf("Expected %s %q, got %q", name, expected, value)
+		}
+	}
+	if received.Host != strings.TrimPrefix(server.URL, "http://") {
+		t.Errorf("Expected the host of the target, got %q", received.Host)
+	}
+}
+
+func TestProxyForwardedHeaders(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	router := NewRouter()
+	router.Proxy("/", []string{server.URL}, ProxyOptions{TrustForwarded: true, PreserveHost: true, KeepPrefix: true})
+
+	r := httptest.NewRequest("GET", "http://example.com/x", nil)
+	r.RemoteAddr = "[2001:db8::1]:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("Forwarded", "for=198.51.100.1;proto=https")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	for name, expected := range map[string]string{
+		"X-Forwarded-For":   "198.51.100.1, 2001:db8::1",
+		"X-Forwarded-Proto": "https",
+		"Forwarded":         `for=198.51.100.1;proto=https, for="[2001:db8::1]";host=example.com;proto=http`,
+	} {
+		if value := received.Header.Get(name); value != expected {
+			t.Errorf("Expected %s %q, got %q", name, expected, value)
+		}
+	}
+	if received.Host != "example.com" || received.URL.Path != "/x" {
+		t.Errorf("Expected the original host and path, got %q %q", received.Host, received.URL.Path)
+	}
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	a, b := backend(t, "a"), backend(t, "b")
+	proxy, err := NewProxy([]string{a.URL, b.URL}, ProxyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for range 4 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		order = append(order, w.Header().Get("X-Backend"))
+	}
+	if strings.Join(order, "") != "abab" {
+		t.Errorf("Expected requests to alternate, got %v", order)
+	}
+}
+
+func TestProxyLeastConnections(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "slow")
+		<-release
+	}))
+	defer slow.Close()
+	fast := backend(t, "fast")
+
+	proxy, _ := NewProxy([]string{slow.URL, fast.URL}, ProxyOptions{Balancer: LeastConnections})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	for proxy.Targets()[0].Active == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 3 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if backend := w.Header().Get("X-Backend"); backend != "fast" {
+			t.Errorf("Expected the idle target, got %q", backend)
+		}
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestProxyLeastConnectionsStreaming(t *testing.T) {
+	release := make(chan struct{})
+	streaming := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "streaming")
+		io.WriteString(w, "first chunk\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer streaming.Close()
+	fast := backend(t, "fast")
+
+	proxy, _ := NewProxy([]string{streaming.URL, fast.URL}, ProxyOptions{Balancer: LeastConnections})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	var unblock sync.Once
+	defer unblock.Do(func() { close(release) })
+
+	// The response headers arrive while its body is still open.
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("X-Backend") != "streaming" || proxy.Targets()[0].Active != 1 {
+		t.Fatalf("Expected the streaming response to stay active, got %q %d", resp.Header.Get("X-Backend"), proxy.Targets()[0].Active)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if backend := w.Header().Get("X-Backend"); backend != "fast" {
+		t.Errorf("Expected the idle target, got %q", backend)
+	}
+
+	unblock.Do(func() { close(release) })
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	waitFor(t, func() bool { return proxy.Targets()[0].Active == 0 })
+}
+
+func TestProxyRetriesAndHealth(t *testing.T) {
+	dead := deadURL(t)
+	alive := backend(t, "alive")
+	proxy, _ := NewProxy([]string{dead, alive.URL}, ProxyOptions{MaxFails: 2, FailTimeout: time.Hour})
+
+	for range 4 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK || w.Header().Get("X-Backend") != "alive" {
+			t.Fatalf("Expected the request to be retried on the live target, got %d", w.Code)
+		}
+	}
+
+	targets := proxy.Targets()
+	if targets[0].Healthy || targets[0].Failures != 2 || !targets[1].Healthy {
+		t.Errorf("Expected the dead target to be taken out of rotation, got %+v", targets)
+	}
+
+	t.Run("not idempotent", func(t *testing.T) {
+		proxy, _ := NewProxy([]string{dead, alive.URL}, ProxyOptions{})
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("payment")))
+
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("Expected a 502 without retry, got %d", w.Code)
+		}
+	})
+
+	t.Run("all down", func(t *testing.T) {
+		proxy, _ := NewProxy([]string{dead}, ProxyOptions{MaxFails: 1})
+		for range 2 {
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("Expected a 502, got %d", w.Code)
+			}
+		}
+	})
+}
+
+func TestProxyUnhealthyStatus(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	alive := backend(t, "alive")
+
+	proxy, _ := NewProxy([]string{failing.URL, alive.URL}, ProxyOptions{MaxFails: 1, FailTimeout: time.Hour})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the response of the target to be passed on, got %d", w.Code)
+	}
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Header().Get("X-Backend") != "alive" {
+			t.Errorf("Expected the failing target to be skipped, got %d", w.Code)
+		}
+	}
+}
+
+func TestNewProxyInvalidTargets(t *testing.T) {
+	for _, targets := range [][]string{nil, {"localhost:8080"}, {"http://%zz"}} {
+		if _, err := NewProxy(targets, ProxyOptions{}); err == nil {
+			t.Errorf("Expected %v to be rejected", targets)
+		}
+	}
+}
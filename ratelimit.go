@@ -0,0 +1,267 @@
+package simplerouter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitAlgorithm selects how RateLimit counts requests.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Burst requests, refilled evenly over
+	// the period. It is implemented as the generic cell rate algorithm, which
+	// stores a single timestamp per key.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow approximates a rolling window by weighting the count of
+	// the previous fixed window with the time left in it.
+	SlidingWindow
+)
+
+// KeyFunc derives the rate limiting key of a request. Requests with an empty
+// key are not limited.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by the IP address of the remote peer. It does not trust
+// forwarding headers; rewrite RemoteAddr upstream when running behind a proxy.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader keys requests by the value of a header, such as an API key.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	// Requests is the number of requests allowed per Period.
+	Requests int
+	Period   time.Duration
+	// Burst is the bucket size of the TokenBucket algorithm. Defaults to
+	// Requests.
+	Burst     int
+	Algorithm RateLimitAlgorithm
+	// Key defaults to KeyByIP.
+	Key KeyFunc
+	// Store defaults to a MemoryStore private to the middleware.
+	Store Store
+	// Name namespaces the keys of this limiter in the Store. Limiters sharing a
+	// Store across processes should set it; it defaults to a name unique to
+	// the middleware within the process.
+	Name string
+
+	now func() time.Time
+}
+
+// RateLimitResult is the outcome of counting a request against a limit.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, set when the
+	// request is denied.
+	RetryAfter time.Duration
+}
+
+var rateLimiterCount atomic.Int64
+
+// RateLimit returns a middleware limiting requests per key. Attach it with Use,
+// to a single route, or to a Route sub-router to choose where the limit
+// applies.
+//
+// Every response carries RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers. Denied requests receive a 429
+// with Retry-After. Store failures let the request through.
+func RateLimit(opts RateLimitOptions) func(http.Handler) http.Handler {
+	if opts.Requests <= 0 || opts.Period <= 0 {
+		panic("simplerouter: RateLimit requires positive Requests and Period")
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Requests
+	}
+	if opts.Key == nil {
+		opts.Key = KeyByIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore(0)
+	}
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("ratelimit%d", rateLimiterCount.Add(1))
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+
+	limiter := &rateLimiter{opts: opts}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := opts.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.take(r.Context(), key)
+			if err != nil {
+				logger.Debug("RateLimit store error", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", opts.Requests, ceilSeconds(opts.Period)))
+
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				writeError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+type rateLimiter struct {
+	opts RateLimitOptions
+}
+
+func (l *rateLimiter) take(ctx context.Context, key string) (RateLimitResult, error) {
+	key = l.opts.Name + ":" + key
+	if l.opts.Algorithm == SlidingWindow {
+		return l.slidingWindow(ctx, key)
+	}
+	return l.tokenBucket(ctx, key)
+}
+
+// tokenBucket implements GCRA: the store holds the theoretical arrival time
+// (TAT) of the next request, in Unix nanoseconds.
+func (l *rateLimiter) tokenBucket(ctx context.Context, key string) (RateLimitResult, error) {
+	interval := l.opts.Period / time.Duration(l.opts.Requests)
+	capacity := interval * time.Duration(l.opts.Burst)
+
+	for range 10 {
+		now := l.opts.now()
+
+		stored, err := l.opts.Store.Get(ctx, key)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+
+		tat := time.Unix(0, stored)
+		if tat.Before(now) {
+			tat = now
+		}
+
+		newTat := tat.Add(interval)
+		allowAt := newTat.Add(-capacity)
+		if now.Before(allowAt) {
+			return RateLimitResult{
+				Limit:      l.opts.Burst,
+				Reset:      tat.Sub(now),
+				RetryAfter: allowAt.Sub(now),
+			}, nil
+		}
+
+		swapped, err := l.opts.Store.CompareAndSwap(ctx, key, stored, newTat.UnixNano(), newTat.Sub(now))
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		if !swapped {
+			continue
+		}
+
+		return RateLimitResult{
+			Allowed:   true,
+			Limit:     l.opts.Burst,
+			Remaining: int(now.Sub(allowAt) / interval),
+			Reset:     newTat.Sub(now),
+		}, nil
+	}
+
+	return RateLimitResult{}, fmt.Errorf("simplerouter: rate limit contention on %q", key)
+}
+
+// slidingWindow counts requests in fixed windows and estimates the rolling
+// count from the current and previous windows. Only allowed requests are
+// counted, so clients retrying while limited do not extend their own block.
+func (l *rateLimiter) slidingWindow(ctx context.Context, key string) (RateLimitResult, error) {
+	period := l.opts.Period
+
+	for range 10 {
+		now := l.opts.now()
+		window := now.UnixNano() / int64(period)
+		elapsed := time.Duration(now.UnixNano() % int64(period))
+		currentKey := fmt.Sprintf("%s:%d", key, window)
+
+		previous, err := l.opts.Store.Get(ctx, fmt.Sprintf("%s:%d", key, window-1))
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		current, err := l.opts.Store.Get(ctx, currentKey)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+
+		weight := float64(period-elapsed) / float64(period)
+		count := int(float64(previous)*weight) + int(current) + 1
+		reset := period - elapsed
+
+		if count > l.opts.Requests {
+			retryAfter := reset
+			if previous > 0 && int(current) < l.opts.Requests {
+				// The previous window's weight keeps decreasing, so a slot frees
+				// up once enough of it has slid out of view.
+				excess := float64(count - l.opts.Requests)
+				retryAfter = time.Duration(excess / float64(previous) * float64(period))
+			}
+			return RateLimitResult{
+				Limit:      l.opts.Requests,
+				Reset:      reset,
+				RetryAfter: retryAfter,
+			}, nil
+		}
+
+		swapped, err := l.opts.Store.CompareAndSwap(ctx, currentKey, current, current+1, 2*period)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		if !swapped {
+			continue
+		}
+
+		return RateLimitResult{
+			Allowed:   true,
+			Limit:     l.opts.Requests,
+			Remaining: l.opts.Requests - count,
+			Reset:     reset,
+		}, nil
+	}
+
+	return RateLimitResult{}, fmt.Errorf("simplerouter: rate limit contention on %q", key)
+}
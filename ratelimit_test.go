@@ -0,0 +1,272 @@
+package simplerouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis emulates the subset of Redis a Store backed by it would use, with
+// values kept as strings and every command recorded.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: map[string]string{}}
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, "GET "+key)
+
+	value, ok := f.values[key]
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (f *fakeRedis) Increment(ctx context.Context, key string, expiry time.Duration) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, "INCR "+key)
+
+	value, _ := strconv.ParseInt(f.values[key], 10, 64)
+	value++
+	f.values[key] = strconv.FormatInt(value, 10)
+	return value, nil
+}
+
+func (f *fakeRedis) CompareAndSwap(ctx context.Context, key string, old, value int64, expiry time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, "EVALSHA cas "+key)
+
+	current, _ := strconv.ParseInt(f.values[key], 10, 64)
+	if current != old {
+		return false, nil
+	}
+	f.values[key] = strconv.FormatInt(value, 10)
+	return true, nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestRateLimit(t *testing.T) {
+	request := func(router *Router, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	newRouter := func(opts RateLimitOptions) *Router {
+		router := NewRouter()
+		router.Get("/test", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}, RateLimit(opts))
+		return router
+	}
+
+	for name, algorithm := range map[string]RateLimitAlgorithm{"token bucket": TokenBucket, "sliding window": SlidingWindow} {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+			router := newRouter(RateLimitOptions{Requests: 3, Period: time.Minute, Algorithm: algorithm, now: clock.Now})
+
+			for i := range 3 {
+				w := request(router, "10.0.0.1:1234")
+				if w.Code != 200 {
+					t.Fatalf("Expected request %d to pass, got %d", i, w.Code)
+				}
+
+				expected := strconv.Itoa(2 - i)
+				if remaining := w.Header().Get("RateLimit-Remaining"); remaining != expected {
+					t.Errorf("Expected RateLimit-Remaining %s, got %s", expected, remaining)
+				}
+			}
+
+			w := request(router, "10.0.0.1:1234")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("Expected status 429, got %d", w.Code)
+			}
+
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header")
+			}
+
+			if w.Header().Get("RateLimit-Limit") != "3" {
+				t.Errorf("Expected RateLimit-Limit 3, got %q", w.Header().Get("RateLimit-Limit"))
+			}
+
+			if w := request(router, "10.0.0.2:1234"); w.Code != 200 {
+				t.Errorf("Expected other clients to pass, got %d", w.Code)
+			}
+
+			clock.Advance(2 * time.Minute)
+			if w := request(router, "10.0.0.1:1234"); w.Code != 200 {
+				t.Errorf("Expected the limit to reset, got %d", w.Code)
+			}
+		})
+	}
+
+	t.Run("token bucket refills gradually", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+		router := newRouter(RateLimitOptions{Requests: 2, Period: time.Second, now: clock.Now})
+
+		request(router, "10.0.0.1:1")
+		request(router, "10.0.0.1:1")
+		if w := request(router, "10.0.0.1:1"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429, got %d", w.Code)
+		}
+
+		clock.Advance(500 * time.Millisecond)
+		if w := request(router, "10.0.0.1:1"); w.Code != 200 {
+			t.Errorf("Expected one token after half the period, got %d", w.Code)
+		}
+	})
+
+	t.Run("sliding window lets clients polling at Retry-After through", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(1_700_000_000, 0).Truncate(time.Minute)}
+		router := newRouter(RateLimitOptions{Requests: 3, Period: time.Minute, Algorithm: SlidingWindow, now: clock.Now})
+
+		for range 3 {
+			request(router, "10.0.0.1:1")
+		}
+		clock.Advance(time.Minute)
+
+		for attempt := range 3 {
+			w := request(router, "10.0.0.1:1")
+			if w.Code == 200 {
+				return
+			}
+			retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+			if retryAfter <= 0 {
+				t.Fatalf("Expected Retry-After on attempt %d, got %q", attempt, w.Header().Get("Retry-After"))
+			}
+			clock.Advance(time.Duration(retryAfter) * time.Second)
+		}
+		t.Error("Expected a request sent after Retry-After to pass")
+	})
+
+	t.Run("keys by header and skips empty keys", func(t *testing.T) {
+		router := newRouter(RateLimitOptions{Requests: 1, Period: time.Minute, Key: KeyByHeader("X-API-Key")})
+
+		for range 3 {
+			if w := request(router, "10.0.0.1:1"); w.Code != 200 {
+				t.Fatalf("Expected requests without a key to pass, got %d", w.Code)
+			}
+		}
+
+		send := func(apiKey string) int {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("X-API-Key", apiKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		if code := send("a"); code != 200 {
+			t.Errorf("Expected status 200, got %d", code)
+		}
+		if code := send("a"); code != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429, got %d", code)
+		}
+		if code := send("b"); code != 200 {
+			t.Errorf("Expected status 200, got %d", code)
+		}
+	})
+
+	t.Run("limits a Route sub-router", func(t *testing.T) {
+		router := NewRouter()
+		router.Get("/open", func(w http.ResponseWriter, r *http.Request) {})
+		router.Route("/api", func(r *Router) {
+			r.Get("/a", func(w http.ResponseWriter, r *http.Request) {})
+			r.Get("/b", func(w http.ResponseWriter, r *http.Request) {})
+		}, RateLimit(RateLimitOptions{Requests: 1, Period: time.Minute}))
+
+		codes := []int{}
+		for _, path := range []string{"/api/a", "/api/b", "/open", "/open"} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			codes = append(codes, w.Code)
+		}
+
+		expected := []int{200, 429, 200, 200}
+		for i := range expected {
+			if codes[i] != expected[i] {
+				t.Errorf("Expected codes %v, got %v", expected, codes)
+				break
+			}
+		}
+	})
+
+	t.Run("works with an external store", func(t *testing.T) {
+		redis := newFakeRedis()
+		clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+
+		for _, algorithm := range []RateLimitAlgorithm{TokenBucket, SlidingWindow} {
+			router := newRouter(RateLimitOptions{
+				Requests:  1,
+				Period:    time.Minute,
+				Algorithm: algorithm,
+				Store:     redis,
+				Name:      "shared",
+				now:       clock.Now,
+			})
+
+			if w := request(router, "10.0.0.1:1"); w.Code != 200 {
+				t.Errorf("Expected status 200, got %d", w.Code)
+			}
+			if w := request(router, "10.0.0.1:1"); w.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected status 429, got %d", w.Code)
+			}
+		}
+
+		if len(redis.commands) == 0 {
+			t.Error("Expected the store to receive commands")
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(2)
+	ctx := context.Background()
+
+	if value, _ := store.Increment(ctx, "a", time.Minute); value != 1 {
+		t.Errorf("Expected 1, got %d", value)
+	}
+	if value, _ := store.Increment(ctx, "a", time.Minute); value != 2 {
+		t.Errorf("Expected 2, got %d", value)
+	}
+
+	if ok, _ := store.CompareAndSwap(ctx, "a", 1, 10, time.Minute); ok {
+		t.Error("Expected CompareAndSwap with a stale value to fail")
+	}
+	if ok, _ := store.CompareAndSwap(ctx, "a", 2, 10, time.Minute); !ok {
+		t.Error("Expected CompareAndSwap to succeed")
+	}
+	if value, _ := store.Get(ctx, "a"); value != 10 {
+		t.Errorf("Expected 10, got %d", value)
+	}
+
+	store.CompareAndSwap(ctx, "b", 0, 5, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if value, _ := store.Get(ctx, "b"); value != 0 {
+		t.Errorf("Expected expired key to read as 0, got %d", value)
+	}
+}
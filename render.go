@@ -0,0 +1,309 @@
+package simplerouter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Renderer encodes response values in one media type for Render.
+type Renderer interface {
+	// ContentType is the media type the renderer produces, such as
+	// "application/json". It may carry parameters like a charset.
+	ContentType() string
+	Render(w io.Writer, v any) error
+}
+
+// CanRenderer is implemented by renderers that only handle some values, like
+// the CSV renderer, which requires a slice of structs. Render skips them for
+// other values when negotiating.
+type CanRenderer interface {
+	CanRender(v any) bool
+}
+
+var renderers = struct {
+	sync.RWMutex
+	list []Renderer
+}{
+	list: []Renderer{jsonRenderer{}, xmlRenderer{}, csvRenderer{}, ndjsonRenderer{}, textRenderer{}},
+}
+
+// RegisterRenderer makes a renderer available to Render, replacing any
+// renderer registered for the same media type. Renderers of new media types
+// are offered after the built-in JSON, XML, CSV, NDJSON and plain text ones.
+func RegisterRenderer(renderer Renderer) {
+	renderers.Lock()
+	defer renderers.Unlock()
+
+	mediaType := mediaTypeOf(renderer.ContentType())
+	for i, existing := range renderers.list {
+		if mediaTypeOf(existing.ContentType()) == mediaType {
+			renderers.list[i] = renderer
+			return
+		}
+	}
+	renderers.list = append(renderers.list, renderer)
+}
+
+// Produces restricts the media types Render may respond with on the route.
+// Routes default to every registered renderer.
+func Produces(mediaTypes ...string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Produces = slices.Clone(mediaTypes)
+	}
+}
+
+// Render writes v with status in the format the Accept header of r prefers,
+// among the formats the route Produces. Requests without an Accept header get
+// the first one, JSON by default. The response is encoded before anything is
+// written, so the returned error can still be passed to WriteError or returned
+// from a HandlerFunc: a 406 listing the available formats when none is
+// acceptable, or the encoding error.
+func Render(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	available := renderersFor(r, v)
+
+	offers := make([]string, len(available))
+	for i, renderer := range available {
+		offers[i] = mediaTypeOf(renderer.ContentType())
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	chosen := negotiateContentType(r.Header.Get("Accept"), offers)
+	if chosen == "" {
+		return &notAcceptableError{available: offers}
+	}
+	renderer := available[slices.Index(offers, chosen)]
+
+	var body bytes.Buffer
+	if err := renderer.Render(&body, v); err != nil {
+		return fmt.Errorf("rendering %s: %w", chosen, err)
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+	return nil
+}
+
+func renderersFor(r *http.Request, v any) []Renderer {
+	var produces []string
+	if route, ok := RouteFromContext(r.Context()); ok {
+		produces = route.Produces
+	}
+
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	var available []Renderer
+	for _, renderer := range renderers.list {
+		if len(produces) > 0 && !slices.Contains(produces, mediaTypeOf(renderer.ContentType())) {
+			continue
+		}
+		if can, ok := renderer.(CanRenderer); ok && !can.CanRender(v) {
+			continue
+		}
+		available = append(available, renderer)
+	}
+
+	if len(produces) > 0 {
+		// Keep the order the route listed its formats in.
+		slices.SortStableFunc(available, func(a, b Renderer) int {
+			return slices.Index(produces, mediaTypeOf(a.ContentType())) - slices.Index(produces, mediaTypeOf(b.ContentType()))
+		})
+	}
+	return available
+}
+
+func mediaTypeOf(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+type notAcceptableError struct {
+	available []string
+}
+
+func (e *notAcceptableError) Error() string {
+	return "none of the acceptable formats are available: " + strings.Join(e.available, ", ")
+}
+
+func (e *notAcceptableError) Problem() *Problem {
+	return NewProblem(http.StatusNotAcceptable, "None of the formats in the Accept header are available.").With("available", e.available)
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string { return "application/json" }
+
+func (jsonRenderer) Render(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type xmlRenderer struct{}
+
+func (xmlRenderer) ContentType() string { return "application/xml; charset=utf-8" }
+
+// CanRender rejects the values encoding/xml cannot write as one well-formed
+// document: maps, which it does not support, and top-level slices, which would
+// produce one root element per item.
+func (xmlRenderer) CanRender(v any) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Chan, reflect.Func:
+		return false
+	}
+	return true
+}
+
+func (xmlRenderer) Render(w io.Writer, v any) error {
+	io.WriteString(w, xml.Header)
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// ndjsonRenderer writes one JSON value per line, one per element of slices.
+type ndjsonRenderer struct{}
+
+func (ndjsonRenderer) ContentType() string { return "application/x-ndjson" }
+
+func (ndjsonRenderer) Render(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return encoder.Encode(v)
+	}
+	for i := range value.Len() {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvRenderer writes slices of structs, with a header row named after the
+// csv or json tags of the fields, and [][]string tables.
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvRenderer) CanRender(v any) bool {
+	if _, ok := v.([][]string); ok {
+		return true
+	}
+	return csvRowType(reflect.TypeOf(v)) != nil
+}
+
+func (csvRenderer) Render(w io.Writer, v any) error {
+	writer := csv.NewWriter(w)
+	if rows, ok := v.([][]string); ok {
+		writer.WriteAll(rows)
+		return writer.Error()
+	}
+
+	rowType := csvRowType(reflect.TypeOf(v))
+	if rowType == nil {
+		return errors.New("csv requires a slice of structs")
+	}
+
+	var columns []int
+	var header []string
+	for i := range rowType.NumField() {
+		field := rowType.Field(i)
+		name := csvColumnName(field)
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		columns = append(columns, i)
+		header = append(header, name)
+	}
+	writer.Write(header)
+
+	value := reflect.ValueOf(v)
+	record := make([]string, len(columns))
+	for i := range value.Len() {
+		row := reflect.Indirect(value.Index(i))
+		for j, column := range columns {
+			record[j] = ""
+			if row.IsValid() {
+				record[j] = fmt.Sprint(row.Field(column).Interface())
+			}
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvRowType(t reflect.Type) reflect.Type {
+	if t == nil || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+		return nil
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil
+	}
+	return elem
+}
+
+func csvColumnName(field reflect.StructField) string {
+	for _, tag := range []string{"csv", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// textRenderer writes strings, byte slices, fmt.Stringers, errors and scalar
+// values.
+type textRenderer struct{}
+
+func (textRenderer) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (textRenderer) CanRender(v any) bool {
+	switch v.(type) {
+	case string, []byte, fmt.Stringer, error:
+		return true
+	}
+	if v == nil {
+		return false
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (textRenderer) Render(w io.Writer, v any) error {
+	var err error
+	switch v := v.(type) {
+	case []byte:
+		_, err = w.Write(v)
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package simplerouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type renderedUser struct {
+	XMLName struct{} `json:"-" csv:"-" xml:"user"`
+	ID      int      `json:"id" xml:"id"`
+	Name    string   `json:"name" csv:"full_name" xml:"name"`
+}
+
+type upperRenderer struct{}
+
+func (upperRenderer) ContentType() string { return "application/vnd.upper" }
+
+func (upperRenderer) Render(w io.Writer, v any) error {
+	_, err := fmt.Fprintf(w, "UPPER %v", v)
+	return err
+}
+
+func TestRender(t *testing.T) {
+	RegisterRenderer(upperRenderer{})
+
+	users := []renderedUser{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Grace"}}
+
+	router := NewRouter()
+	router.Get("/users", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, users)
+	}))
+	router.Get("/users/1", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, users[0])
+	}))
+	router.Get("/labels", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, map[string]string{"team": "compilers"})
+	}))
+	router.Get("/count", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, len(users))
+	}))
+	router.With(Produces("text/csv", "application/json")).Get("/export", Func(func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, users)
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+	}{
+		{"defaults to JSON", "/users", "", 200, "application/json", `[{"id":1,"name":"Ada"},{"id":2,"name":"Grace"}]` + "\n"},
+		{"xml", "/users/1", "application/xml", 200, "application/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<user><id>1</id><name>Ada</name></user>"},
+		{"csv", "/users", "text/csv", 200, "text/csv; charset=utf-8", "id,full_name\n1,Ada\n2,Grace\n"},
+		{"ndjson", "/users", "application/x-ndjson", 200, "application/x-ndjson", `{"id":1,"name":"Ada"}` + "\n" + `{"id":2,"name":"Grace"}` + "\n"},
+		{"text", "/count", "text/plain", 200, "text/plain; charset=utf-8", "2"},
+		{"custom renderer", "/count", "application/vnd.upper", 200, "application/vnd.upper", "UPPER 2"},
+		{"quality values", "/users", "application/json;q=0.5, text/csv", 200, "text/csv; charset=utf-8", "id,full_name\n1,Ada\n2,Grace\n"},
+		{"route formats come first", "/export", "*/*", 200, "text/csv; charset=utf-8", "id,full_name\n1,Ada\n2,Grace\n"},
+		{"csv needs a slice of structs", "/users/1", "text/csv", 406, "application/problem+json", ""},
+		{"xml needs a single root", "/users", "application/xml", 406, "application/problem+json", ""},
+		{"xml cannot encode maps", "/labels", "application/xml", 406, "application/problem+json", ""},
+		{"route restricts formats", "/export", "application/xml", 406, "application/problem+json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.expectedType, contentType)
+			}
+
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", vary)
+			}
+		})
+	}
+
+	t.Run("406 lists the available formats", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/export", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body struct {
+			Available []string `json:"available"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+
+		if len(body.Available) != 2 || body.Available[0] != "text/csv" || body.Available[1] != "application/json" {
+			t.Errorf("Expected the route formats, got %v", body.Available)
+		}
+	})
+
+	t.Run("routes expose their formats", func(t *testing.T) {
+		for _, route := range router.Routes() {
+			if route.Pattern == "GET /export" && len(route.Produces) != 2 {
+				t.Errorf("Expected the route to produce two formats, got %v", route.Produces)
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package simplerouter
 
 import (
+	"context"
 	"net/http"
 	"slices"
 	"strings"
@@ -9,8 +10,9 @@ import (
 type (
 	middleware func(http.Handler) http.Handler
 	Router     struct {
-		mux   *muxWrapper
-		chain []middleware
+		mux     *muxWrapper
+		chain   []middleware
+		options []RouteOption
 	}
 )
 
@@ -18,7 +20,10 @@ type muxWrapper struct {
 	*http.ServeMux
 	httpHandler     middleware
 	notFoundHandler http.Handler
+	errorHandler    ErrorHandler
 	rootPath        string
+	routes          *routeTable
+	manualOptions   bool
 }
 
 func (m *muxWrapper) fullPattern(pattern string) string {
@@ -26,61 +31,126 @@ func (m *muxWrapper) fullPattern(pattern string) string {
 		return pattern
 	}
 
-	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
-		method, path := pattern[:i], strings.TrimLeft(pattern[i:], " \t")
+	if method, path := splitPattern(pattern); method != "" {
 		return method + " " + m.rootPath + path
 	}
 
 	return m.rootPath + pattern
 }
 
+// splitPattern separates the optional method prefix of a ServeMux pattern from
+// the host and path portion.
+func splitPattern(pattern string) (method, path string) {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		return pattern[:i], strings.TrimLeft(pattern[i:], " \t")
+	}
+	return "", pattern
+}
+
 func (m *muxWrapper) Handle(pattern string, handler http.Handler) {
+	m.handleRoute(pattern, handler, nil, nil, nil)
+}
+
+// handleRoute registers handler like Handle and records the route with opts
+// applied. sub is the mux of a mounted Router, used to resolve the final route
+// of a request before any middleware runs.
+func (m *muxWrapper) handleRoute(pattern string, handler http.Handler, opts []RouteOption, sub *muxWrapper, chain middleware) {
 	pattern = m.fullPattern(pattern)
+	info := newRouteInfo(pattern, opts)
+
+	// Routes of a mounted Router apply the breaker themselves.
+	if info.Breaker != nil && sub == nil {
+		handler = info.Breaker.Middleware(handler)
+	}
+
 	logger.Debug("Handle", "pattern", pattern)
 	m.ServeMux.Handle(pattern, handler)
+	m.routes.add(m, info, sub, chain)
 
 	if strings.Contains(pattern, "-") {
 		logger.Debug("Handle -", "pattern", strings.ReplaceAll(pattern, "-", "_"))
 		m.ServeMux.Handle(strings.ReplaceAll(pattern, "-", "_"), handler)
+		m.routes.add(m, info.alias(strings.ReplaceAll(pattern, "-", "_")), sub, chain)
 	} else if strings.Contains(pattern, "_") {
 		logger.Debug("Handle _", "pattern", strings.ReplaceAll(pattern, "_", "-"))
 		m.ServeMux.Handle(strings.ReplaceAll(pattern, "_", "-"), handler)
+		m.routes.add(m, info.alias(strings.ReplaceAll(pattern, "_", "-")), sub, chain)
 	}
 }
 
-func (m *muxWrapper) HandleFunc(pattern string, handler http.HandlerFunc) {
-	pattern = m.fullPattern(pattern)
-
-	m.ServeMux.HandleFunc(pattern, handler)
+// match resolves the route a request will be dispatched to, descending into
+// mounted sub-routers.
+func (m *muxWrapper) match(r *http.Request) (RouteInfo, bool) {
+	_, pattern := m.ServeMux.Handler(r)
+	if pattern == "" {
+		return RouteInfo{}, false
+	}
 
-	if strings.Contains(pattern, "-") {
-		m.ServeMux.HandleFunc(strings.ReplaceAll(pattern, "-", "_"), handler)
-	} else if strings.Contains(pattern, "_") {
-		m.ServeMux.HandleFunc(strings.ReplaceAll(pattern, "_", "-"), handler)
+	route, sub, ok := m.routes.find(m, pattern)
+	if ok && sub != nil {
+		if route.StripPrefix {
+			r, _ = stripRequest(r, route.Path)
+		}
+		if inner, ok := sub.match(r); ok {
+			return inner, true
+		}
 	}
+
+	return route, ok
+}
+
+func (m *muxWrapper) HandleFunc(pattern string, handler http.HandlerFunc) {
+	m.Handle(pattern, handler)
 }
 
 func (m *muxWrapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("Handling Path", "path", r.URL.Path)
 	w = toStatusInterceptor(w, r)
 
-	if m.notFoundHandler != nil {
-		_, matchedPattern := m.ServeMux.Handler(r)
-		if matchedPattern == "" {
-			m.notFoundHandler.ServeHTTP(w, r)
-			return
+	ctx := r.Context()
+	if _, ok := RouteFromContext(ctx); !ok {
+		if route, ok := m.match(r); ok {
+			ctx = context.WithValue(ctx, routeContextKey{}, route)
+		}
+	}
+	if m.errorHandler != nil {
+		ctx = context.WithValue(ctx, errorHandlerContextKey{}, m.errorHandler)
+	}
+	if ctx != r.Context() {
+		r = r.WithContext(ctx)
+	}
+
+	var next http.Handler = m.ServeMux
+
+	if r.Method == http.MethodOptions && !m.manualOptions {
+		if allow := m.allowedMethods(r); len(allow) > 0 {
+			next = m.autoOptionsHandler(r, allow)
+		}
+	}
+
+	if next == http.Handler(m.ServeMux) {
+		if _, matchedPattern := m.ServeMux.Handler(r); matchedPattern == "" {
+			if m.notFoundHandler != nil {
+				m.notFoundHandler.ServeHTTP(w, r)
+				return
+			}
+			next = m.unmatchedHandler(r)
 		}
 	}
 
 	if m.httpHandler != nil {
-		m.httpHandler(m.ServeMux).ServeHTTP(w, r)
+		m.httpHandler(next).ServeHTTP(w, r)
 	} else {
-		m.ServeMux.ServeHTTP(w, r)
+		next.ServeHTTP(w, r)
 	}
 }
 
 func newMuxWrapper(paths ...string) *muxWrapper {
-	return &muxWrapper{ServeMux: http.NewServeMux(), rootPath: buildRootPath(paths...)}
+	return &muxWrapper{
+		ServeMux: http.NewServeMux(),
+		rootPath: buildRootPath(paths...),
+		routes:   &routeTable{},
+	}
 }
 
 func buildRootPath(paths ...string) string {
@@ -107,6 +177,29 @@ func (r *Router) SetNotFoundHandler(handler http.Handler) {
 	r.mux.notFoundHandler = handler
 }
 
+// SetErrorHandler sets the handler mapping errors returned by HandlerFunc
+// handlers, and the errors generated by the router and its middleware, to
+// responses. Route sub-routers without their own ErrorHandler inherit it.
+func (r *Router) SetErrorHandler(handler ErrorHandler) {
+	r.mux.errorHandler = handler
+}
+
+// SetAutoOptions controls whether OPTIONS requests without an explicit Options
+// handler are answered automatically with a 204 and an Allow header computed
+// from the registered routes. The answer runs through the middleware of the
+// GET route of the path, or else of its first route by method name, so auth
+// and CORS middleware see preflight requests. It is enabled by default.
+func (r *Router) SetAutoOptions(enabled bool) {
+	r.mux.manualOptions = !enabled
+}
+
+// Routes returns every pattern registered on the Router, including those of
+// sub-routers created with Route and the aliases registered for patterns
+// containing dashes or underscores.
+func (r *Router) Routes() []RouteInfo {
+	return r.mux.routes.list()
+}
+
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.mux.ServeHTTP(w, req)
 }
@@ -133,11 +226,24 @@ func (r *Router) Use(chain ...middleware) {
 	r.chain = append(r.chain, chain...)
 }
 
+// With returns a router sharing the same mux and a cloned middleware stack,
+// whose routes are registered with the given options in addition to the ones
+// already set on r.
+//
+//	r.With(BodyLimit(32 << 20)).Post("/uploads", upload)
+func (r *Router) With(opts ...RouteOption) *Router {
+	return &Router{
+		mux:     r.mux,
+		chain:   slices.Clone(r.chain),
+		options: append(slices.Clone(r.options), opts...),
+	}
+}
+
 // Creates a sub-router with the a cloned middleware stack.
 // this router uses the same ServeMux as the parent router, but the middleware
 // stack is independent of external changes to the parent router.
 func (r *Router) Group(fn func(r *Router)) {
-	fn(&Router{mux: r.mux, chain: slices.Clone(r.chain)})
+	fn(&Router{mux: r.mux, chain: slices.Clone(r.chain), options: slices.Clone(r.options)})
 }
 
 // Creates a sub-router with the a cloned middleware stack.
@@ -148,23 +254,62 @@ func (r *Router) Route(path string, fn func(r *Router), chain ...middleware) *Ro
 			ServeMux:        http.NewServeMux(),
 			rootPath:        buildRootPath(r.mux.rootPath, path),
 			notFoundHandler: r.mux.notFoundHandler,
+			routes:          r.mux.routes,
+			manualOptions:   r.mux.manualOptions,
 		},
-		chain: chain,
+		chain:   chain,
+		options: slices.Clone(r.options),
 	}
 
 	if fn != nil {
 		fn(subRouter)
 	}
 
-	r.Mount(path, subRouter)
+	// The sub-router matches full paths itself, whatever mount options are
+	// set on r.
+	r.mount(path, subRouter, nil, append(slices.Clone(r.options), resetMountOptions))
 
 	return subRouter
 }
 
+// Mount registers h for path and every path below it, for any method. The
+// StripPrefix, TrustForwardedPrefix, MountMethods and MountExact options set
+// with With change how it is mounted:
+//
+//	r.With(StripPrefix()).Mount("/debug/pprof", http.DefaultServeMux)
+//	r.With(StripPrefix(), MountMethods(http.MethodGet)).Mount("/files", http.FileServer(dir))
 func (r *Router) Mount(path string, h http.Handler, chain ...middleware) {
-	path = strings.TrimSuffix(path, "/") + "/"
+	r.mount(path, h, chain, r.options)
+}
+
+func (r *Router) mount(path string, h http.Handler, chain []middleware, opts []RouteOption) {
+	settings := newRouteInfo(path, opts)
+
+	path = strings.TrimSuffix(path, "/")
+	switch {
+	case !settings.MountExact:
+		path += "/"
+	case path == "":
+		path = "/{$}"
+	}
+
+	var sub *muxWrapper
+	if router, ok := h.(*Router); ok {
+		sub = router.mux
+	}
 
-	r.mux.Handle(path, r.wrap(h.ServeHTTP, chain))
+	if settings.StripPrefix {
+		h = stripMountPrefix(r.mux.fullPattern(path), settings.TrustForwardedPrefix, h)
+	}
+	handler := r.wrap(h.ServeHTTP, chain)
+
+	if len(settings.MountMethods) == 0 {
+		r.mux.handleRoute(path, handler, opts, sub, nil)
+		return
+	}
+	for _, method := range settings.MountMethods {
+		r.mux.handleRoute(method+" "+path, handler, opts, sub, nil)
+	}
 }
 
 func (r *Router) Get(path string, fn http.HandlerFunc, chain ...middleware) {
@@ -192,7 +337,7 @@ func (r *Router) Options(path string, fn http.HandlerFunc, chain ...middleware)
 }
 
 func (r *Router) Any(path string, fn http.HandlerFunc, chain ...middleware) {
-	r.mux.Handle(path, r.wrap(fn, chain))
+	r.mux.handleRoute(path, r.wrap(fn, chain), r.options, nil, nil)
 }
 
 // allow dynamic methods
@@ -204,13 +349,20 @@ func (r *Router) NotFound(writer http.ResponseWriter, req *http.Request) {
 	if r.mux.notFoundHandler != nil {
 		r.mux.notFoundHandler.ServeHTTP(writer, req)
 	} else {
-		writer.WriteHeader(http.StatusNotFound)
-		writer.Write([]byte(`{"error": "Not Found"}`))
+		writeError(writer, req, http.StatusNotFound)
 	}
 }
 
 func (r *Router) handle(method, path string, fn http.HandlerFunc, chain []middleware) {
-	r.mux.Handle(method+" "+path, r.wrap(fn, chain))
+	r.mux.handleRoute(method+" "+path, r.wrap(fn, chain), r.options, nil, func(h http.Handler) http.Handler {
+		return r.wrap(h.ServeHTTP, chain)
+	})
+}
+
+// withDefaults returns a router registering routes with opts ahead of the
+// options of r, so options set with With override them.
+func (r *Router) withDefaults(opts ...RouteOption) *Router {
+	return &Router{mux: r.mux, chain: r.chain, options: append(opts, r.options...)}
 }
 
 func (r *Router) wrap(fn http.HandlerFunc, chain []middleware) (out http.Handler) {
@@ -0,0 +1,298 @@
+package simplerouter
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// RouteInfo describes a single pattern registered on a Router.
+type RouteInfo struct {
+	// Method is the HTTP method of the pattern, or empty for patterns that
+	// match every method (Any, Route, and Mount without MountMethods).
+	Method string
+	// Pattern is the full pattern as registered with the http.ServeMux,
+	// including the method and the base path of the Router.
+	Pattern string
+	// Path is the host and path portion of Pattern.
+	Path string
+	// Alias is set for the dash/underscore variant registered alongside the
+	// original pattern.
+	Alias bool
+	// BodyLimit overrides the request body limit enforced by DecodeBody when
+	// greater than zero.
+	BodyLimit int64
+	// Priority ranks the route for ConcurrencyLimit when requests queue;
+	// higher values are admitted first and shed last.
+	Priority int
+	// Breaker is the circuit breaker guarding the route, if any.
+	Breaker *Breaker
+	// Produces lists the media types Render may respond with on the route,
+	// or nil for every registered renderer.
+	Produces []string
+
+	// OperationID, Summary, Description, Tags and Deprecated document the
+	// route in the OpenAPI document of the Router.
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Request is the type requests are bound to, set by HandleJSON and
+	// HandleBind or with Accepts.
+	Request reflect.Type
+	// Response is the type of successful responses, written with
+	// ResponseStatus. It is set by HandleJSON or with Returns.
+	Response       reflect.Type
+	ResponseStatus int
+
+	// StripPrefix, TrustForwardedPrefix, MountMethods and MountExact
+	// configure routes registered with Mount, and are ignored by other
+	// routes.
+	StripPrefix          bool
+	TrustForwardedPrefix bool
+	MountMethods         []string
+	MountExact           bool
+}
+
+// RouteOption customizes the RouteInfo of routes registered through a Router
+// returned by With.
+type RouteOption func(*RouteInfo)
+
+// BodyLimit sets the maximum request body size, before and after
+// decompression, enforced by DecodeBody for the route.
+func BodyLimit(bytes int64) RouteOption {
+	return func(route *RouteInfo) {
+		route.BodyLimit = bytes
+	}
+}
+
+// Priority sets the priority class ConcurrencyLimit uses for the route.
+// Routes default to zero; use negative values for work that may be shed first.
+func Priority(level int) RouteOption {
+	return func(route *RouteInfo) {
+		route.Priority = level
+	}
+}
+
+// OperationID sets the operationId of the route in the OpenAPI document.
+func OperationID(id string) RouteOption {
+	return func(route *RouteInfo) {
+		route.OperationID = id
+	}
+}
+
+// Summary sets the summary of the route in the OpenAPI document.
+func Summary(summary string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Summary = summary
+	}
+}
+
+// Description sets the description of the route in the OpenAPI document.
+func Description(description string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Description = description
+	}
+}
+
+// Tags adds tags grouping the route in the OpenAPI document.
+func Tags(tags ...string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Tags = append(slices.Clip(route.Tags), tags...)
+	}
+}
+
+// Deprecated marks the route as deprecated in the OpenAPI document.
+func Deprecated() RouteOption {
+	return func(route *RouteInfo) {
+		route.Deprecated = true
+	}
+}
+
+// Accepts documents the request type of a route whose handler binds requests
+// itself.
+func Accepts[T any]() RouteOption {
+	return func(route *RouteInfo) {
+		route.Request = reflect.TypeFor[T]()
+	}
+}
+
+// Returns documents the type and status of successful responses of a route
+// whose handler writes them itself.
+func Returns[T any](status int) RouteOption {
+	return func(route *RouteInfo) {
+		route.Response = reflect.TypeFor[T]()
+		route.ResponseStatus = status
+	}
+}
+
+// StripPrefix removes the mount path, including the base path of the Router,
+// from the request before it reaches a handler registered with Mount, so stock
+// handlers such as http.FileServer see paths relative to where they are
+// mounted. The removed prefix is set as X-Forwarded-Prefix, or appended to
+// the one of an enclosing StripPrefix mount, and the unstripped path remains
+// available through OriginalPath.
+func StripPrefix() RouteOption {
+	return func(route *RouteInfo) {
+		route.StripPrefix = true
+	}
+}
+
+// TrustForwardedPrefix appends the prefix removed by StripPrefix to the
+// X-Forwarded-Prefix sent by the client, for routers behind a proxy that sets
+// it. Without it, the header of the client is replaced, so clients cannot
+// inject prefixes into generated URLs and redirects.
+func TrustForwardedPrefix() RouteOption {
+	return func(route *RouteInfo) {
+		route.TrustForwardedPrefix = true
+	}
+}
+
+// MountMethods restricts a handler registered with Mount to the given methods;
+// other methods get a 405. GET includes HEAD.
+func MountMethods(methods ...string) RouteOption {
+	return func(route *RouteInfo) {
+		route.MountMethods = append(slices.Clip(route.MountMethods), methods...)
+	}
+}
+
+// MountExact registers a handler with Mount for its path alone instead of the
+// whole subtree below it.
+func MountExact() RouteOption {
+	return func(route *RouteInfo) {
+		route.MountExact = true
+	}
+}
+
+type routeContextKey struct{}
+
+// RouteFromContext returns the route matched for the request carrying ctx. It
+// is available to middleware and handlers of any router in the tree, including
+// middleware registered on a parent of the Route sub-router that owns the
+// route.
+func RouteFromContext(ctx context.Context) (RouteInfo, bool) {
+	route, ok := ctx.Value(routeContextKey{}).(RouteInfo)
+	return route, ok
+}
+
+type routeEntry struct {
+	info  RouteInfo
+	owner *muxWrapper
+	sub   *muxWrapper
+	// chain applies the middleware of the route, so automatic OPTIONS answers
+	// for its path run through it too.
+	chain middleware
+}
+
+// routeTable records every pattern registered by a muxWrapper. Sub-routers
+// created with Route share the table of their parent so the root Router can
+// describe the whole tree.
+type routeTable struct {
+	mu     sync.RWMutex
+	routes []routeEntry
+	index  map[routeKey]int
+}
+
+type routeKey struct {
+	owner   *muxWrapper
+	pattern string
+}
+
+func newRouteInfo(pattern string, opts []RouteOption) RouteInfo {
+	method, path := splitPattern(pattern)
+	info := RouteInfo{Method: method, Pattern: pattern, Path: path}
+	for _, opt := range opts {
+		opt(&info)
+	}
+	return info
+}
+
+// alias returns a copy of the route registered under the given alias pattern.
+func (info RouteInfo) alias(pattern string) RouteInfo {
+	info.Pattern = pattern
+	info.Method, info.Path = splitPattern(pattern)
+	info.Alias = true
+	return info
+}
+
+func (t *routeTable) add(owner *muxWrapper, info RouteInfo, sub *muxWrapper, chain middleware) {
+	if t == nil {
+		return
+	}
+
+	pattern := info.Pattern
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.index == nil {
+		t.index = map[routeKey]int{}
+	}
+	t.index[routeKey{owner, pattern}] = len(t.routes)
+	t.routes = append(t.routes, routeEntry{info: info, owner: owner, sub: sub, chain: chain})
+}
+
+func (t *routeTable) find(owner *muxWrapper, pattern string) (RouteInfo, *muxWrapper, bool) {
+	if t == nil {
+		return RouteInfo{}, nil, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i, ok := t.index[routeKey{owner, pattern}]
+	if !ok {
+		return RouteInfo{}, nil, false
+	}
+	return t.routes[i].info, t.routes[i].sub, true
+}
+
+// chain returns the middleware of the route registered by owner under
+// pattern, or nil.
+func (t *routeTable) chain(owner *muxWrapper, pattern string) middleware {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i, ok := t.index[routeKey{owner, pattern}]
+	if !ok {
+		return nil
+	}
+	return t.routes[i].chain
+}
+
+func (t *routeTable) list() []RouteInfo {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	routes := make([]RouteInfo, len(t.routes))
+	for i, entry := range t.routes {
+		routes[i] = entry.info
+	}
+	return routes
+}
+
+// methods returns the sorted set of explicit methods found in the table.
+func (t *routeTable) methods() []string {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var methods []string
+	for _, entry := range t.routes {
+		if method := entry.info.Method; method != "" && !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	slices.Sort(methods)
+	return methods
+}
@@ -0,0 +1,380 @@
+package simplerouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	// RPCServerError is the code of errors mapped from HTTP statuses other
+	// than 400, 422 and 5xx.
+	RPCServerError = -32000
+)
+
+// RPCError is a JSON-RPC error object. Methods may return it to choose the
+// code, message and data of their error responses.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// RPCOptions configures an RPCServer. The zero value is usable.
+type RPCOptions struct {
+	// MaxBatch is the largest number of calls in a batch. Defaults to 100.
+	MaxBatch int
+}
+
+// RPCServer is a JSON-RPC 2.0 handler dispatching calls to typed methods,
+// registered with HandleRPC, and attached to a route with Router.Post or
+// Mount:
+//
+//	rpc := NewRPCServer(RPCOptions{})
+//	HandleRPC(rpc, "users.get", func(ctx context.Context, p GetUser) (*User, error) {
+//		return users.Find(ctx, p.ID)
+//	}, RequireScope("users:read"))
+//	r.Post("/rpc", rpc.ServeHTTP)
+//
+// Every call runs through the middleware of the server and of its method as a
+// request of its own, carrying the headers of the HTTP request and the params
+// as its body. Middleware answering with an error status, such as 401,
+// fails only that call.
+type RPCServer struct {
+	opts    RPCOptions
+	chain   []middleware
+	methods map[string]http.Handler
+}
+
+// NewRPCServer returns an RPCServer running chain around every call.
+func NewRPCServer(opts RPCOptions, chain ...middleware) *RPCServer {
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 100
+	}
+	return &RPCServer{opts: opts, chain: chain, methods: map[string]http.Handler{}}
+}
+
+// HandleRPC registers fn as the method name of s, running chain around its
+// calls. Params are decoded into P, rejecting unknown fields, and checked with
+// Validate; absent params leave P as its zero value. Errors returned by fn are
+// mapped to JSON-RPC errors: *RPCError as is, statuses 400 and 422 to invalid
+// params, 5xx to internal errors and other statuses to RPCServerError, each
+// with the problem details of the error as data. It panics if name is already
+// registered.
+func HandleRPC[P, R any](s *RPCServer, name string, fn func(context.Context, P) (R, error), chain ...middleware) {
+	if _, ok := s.methods[name]; ok {
+		panic("simplerouter: JSON-RPC method " + name + " registered twice")
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Context().Value(rpcCallContextKey{}).(*rpcCall)
+		call.ran = true
+
+		var params P
+		if len(call.params) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(call.params))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&params); err != nil {
+				call.err = jsonDecodeError(err)
+				return
+			}
+		}
+		if err := Validate(&params); err != nil {
+			call.err = err
+			return
+		}
+
+		call.result, call.err = fn(r.Context(), params)
+	})
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	for i := len(s.chain) - 1; i >= 0; i-- {
+		handler = s.chain[i](handler)
+	}
+	s.methods[name] = handler
+}
+
+// Methods returns the names of the registered methods, sorted.
+func (s *RPCServer) Methods() []string {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type rpcCallContextKey struct{}
+
+// rpcCall carries a call through the middleware of its method.
+type rpcCall struct {
+	method string
+	params json.RawMessage
+
+	ran    bool
+	result any
+	err    error
+}
+
+// RPCMethodFromContext returns the JSON-RPC method of the call being served.
+func RPCMethodFromContext(ctx context.Context) (string, bool) {
+	call, ok := ctx.Value(rpcCallContextKey{}).(*rpcCall)
+	if !ok {
+		return "", false
+	}
+	return call.method, true
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  *string         `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var rpcNullID = json.RawMessage("null")
+
+func (s *RPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !isJSONMediaType(mediaType) {
+			WriteError(w, r, Errorf(http.StatusUnsupportedMediaType, "expected a JSON body, got %q", contentType))
+			return
+		}
+	}
+
+	limit := int64(DefaultJSONBodyLimit)
+	if route, ok := RouteFromContext(r.Context()); ok && route.BodyLimit > 0 {
+		limit = route.BodyLimit
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		WriteError(w, r, jsonDecodeError(err))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		writeRPC(w, rpcError(rpcNullID, &RPCError{Code: RPCParseError, Message: "Parse error"}))
+		return
+	}
+
+	if body[0] != '[' {
+		if response, ok := s.call(r, body); ok {
+			writeRPC(w, response)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	json.Unmarshal(body, &batch)
+	if len(batch) == 0 || len(batch) > s.opts.MaxBatch {
+		message := "Invalid Request"
+		if len(batch) > 0 {
+			message = fmt.Sprintf("Invalid Request: batches are limited to %d calls", s.opts.MaxBatch)
+		}
+		writeRPC(w, rpcError(rpcNullID, &RPCError{Code: RPCInvalidRequest, Message: message}))
+		return
+	}
+
+	responses := []rpcResponse{}
+	for _, message := range batch {
+		if response, ok := s.call(r, message); ok {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRPC(w, responses)
+}
+
+// call serves one request object, reporting false for notifications.
+func (s *RPCServer) call(r *http.Request, message json.RawMessage) (rpcResponse, bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(message, &req); err != nil || req.JSONRPC != "2.0" || req.Method == nil || !validRPCID(req.ID) || !validRPCParams(req.Params) {
+		id := rpcNullID
+		if err == nil && validRPCID(req.ID) && req.ID != nil {
+			id = req.ID
+		}
+		return rpcError(id, &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"}), true
+	}
+
+	response := s.dispatch(r, *req.Method, req.ID, req.Params)
+	if req.ID == nil {
+		if response.Error != nil {
+			logger.Debug("JSON-RPC notification failed", "method", *req.Method, "error", response.Error.Message)
+		}
+		return rpcResponse{}, false
+	}
+	return response, true
+}
+
+// dispatch runs a call through the middleware of its method.
+func (s *RPCServer) dispatch(r *http.Request, method string, id, params json.RawMessage) rpcResponse {
+	handler, ok := s.methods[method]
+	if !ok {
+		return rpcError(id, &RPCError{Code: RPCMethodNotFound, Message: "Method not found", Data: method})
+	}
+
+	call := &rpcCall{method: method, params: params}
+	ctx := context.WithValue(r.Context(), rpcCallContextKey{}, call)
+	if route, ok := RouteFromContext(ctx); ok {
+		route.OperationID = method
+		ctx = context.WithValue(ctx, routeContextKey{}, route)
+	}
+	req := r.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(params))
+	req.ContentLength = int64(len(params))
+
+	recorder := &responseRecorder{header: http.Header{}}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Debug("JSON-RPC method panicked", "method", method, "panic", p)
+				call.ran, call.err = true, fmt.Errorf("panic: %v", p)
+			}
+		}()
+		handler.ServeHTTP(recorder, req)
+	}()
+
+	switch {
+	case call.ran && call.err != nil:
+		return rpcError(id, rpcErrorOf(call.err))
+	case call.ran:
+		result := call.result
+		if isNil(result) {
+			result = json.RawMessage("null")
+		}
+		return rpcResponse{JSONRPC: "2.0", Result: result, ID: id}
+	}
+
+	// Middleware answered in place of the method.
+	status := recorder.status
+	if status < http.StatusBadRequest {
+		status = http.StatusInternalServerError
+	}
+	problem := &Problem{Status: status}
+	if strings.HasSuffix(recorder.header.Get("Content-Type"), "json") {
+		json.Unmarshal(recorder.body.Bytes(), &struct {
+			Title  *string `json:"title"`
+			Detail *string `json:"detail"`
+		}{&problem.Title, &problem.Detail})
+	}
+	return rpcError(id, rpcErrorOf(problem))
+}
+
+// rpcErrorOf maps an error to a JSON-RPC error object.
+func rpcErrorOf(err error) *RPCError {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	problem := ProblemOf(err)
+	switch {
+	case problem.Status == http.StatusBadRequest, problem.Status == http.StatusUnprocessableEntity:
+		return &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: problem}
+	case problem.Status >= 500:
+		if problem.Status == http.StatusInternalServerError {
+			logger.Debug("JSON-RPC method failed", "error", err)
+		}
+		return &RPCError{Code: RPCInternalError, Message: "Internal error", Data: problem}
+	}
+	message := problem.Detail
+	if message == "" {
+		message = problem.title()
+	}
+	return &RPCError{Code: RPCServerError, Message: message, Data: problem}
+}
+
+func rpcError(id json.RawMessage, err *RPCError) rpcResponse {
+	if id == nil {
+		id = rpcNullID
+	}
+	return rpcResponse{JSONRPC: "2.0", Error: err, ID: id}
+}
+
+// validRPCID reports whether id is absent, a string, a number or null.
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '{', '[', 't', 'f':
+		return false
+	}
+	return true
+}
+
+// validRPCParams reports whether params are absent, an array or an object.
+func validRPCParams(params json.RawMessage) bool {
+	return params == nil || params[0] == '[' || params[0] == '{'
+}
+
+func writeRPC(w http.ResponseWriter, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		body, _ = json.Marshal(rpcError(rpcNullID, &RPCError{Code: RPCInternalError, Message: "Internal error"}))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// responseRecorder captures a response written in process, such as by
+// middleware answering a JSON-RPC call itself or by a batched request.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 && code >= 200 {
+		rr.status = code
+	}
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	return rr.body.Write(p)
+}
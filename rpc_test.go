@@ -0,0 +1,208 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b" validate:"max=100"`
+}
+
+func newTestRPC(t *testing.T) (*Router, *[]string) {
+	var notified []string
+	requireToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer admin" {
+				WriteError(w, r, NewError(http.StatusForbidden, "admin only"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	tagMethod := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, _ := RPCMethodFromContext(r.Context())
+			route, _ := RouteFromContext(r.Context())
+			if method != route.OperationID {
+				t.Errorf("Expected the route to name the method, got %q and %q", method, route.OperationID)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	rpc := NewRPCServer(RPCOptions{MaxBatch: 3}, tagMethod)
+	HandleRPC(rpc, "add", func(ctx context.Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	HandleRPC(rpc, "sum", func(ctx context.Context, p []int) (int, error) {
+		total := 0
+		for _, n := range p {
+			total += n
+		}
+		return total, nil
+	})
+	HandleRPC(rpc, "notify", func(ctx context.Context, p struct{ Event string }) (any, error) {
+		notified = append(notified, p.Event)
+		return nil, nil
+	})
+	HandleRPC(rpc, "fail", func(ctx context.Context, p struct{ Kind string }) (any, error) {
+		switch p.Kind {
+		case "rpc":
+			return nil, &RPCError{Code: 42, Message: "custom", Data: "details"}
+		case "missing":
+			return nil, NewError(http.StatusNotFound, "no such user")
+		}
+		return nil, errors.New("database password leaked")
+	})
+	HandleRPC(rpc, "admin.reset", func(ctx context.Context, p struct{}) (bool, error) {
+		return true, nil
+	}, requireToken)
+
+	router := NewRouter()
+	router.Post("/rpc", rpc.ServeHTTP)
+	return router, &notified
+}
+
+func postRPC(router *Router, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestRPC(t *testing.T) {
+	router, _ := newTestRPC(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"named params", `{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2},"id":1}`, `{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"positional params", `{"jsonrpc":"2.0","method":"sum","params":[1,2,3],"id":"x"}`, `{"jsonrpc":"2.0","result":6,"id":"x"}`},
+		{"null result", `{"jsonrpc":"2.0","method":"notify","params":{"Event":"a"},"id":null}`, `{"jsonrpc":"2.0","result":null,"id":null}`},
+		{"parse error", `{"jsonrpc":"2.0",`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"invalid request", `{"jsonrpc":"1.0","method":"add","id":3}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":3}`},
+		{"invalid params type", `{"jsonrpc":"2.0","method":"add","params":"1,2","id":4}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":4}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"nope","id":5}`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":"nope"},"id":5}`},
+		{"rpc error", `{"jsonrpc":"2.0","method":"fail","params":{"Kind":"rpc"},"id":6}`, `{"jsonrpc":"2.0","error":{"code":42,"message":"custom","data":"details"},"id":6}`},
+		{"empty batch", `[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := postRPC(router, test.body)
+			if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != test.expected {
+				t.Errorf("Expected %s, got %d %s", test.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	router, _ := newTestRPC(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		header  []string
+		code    int
+		message string
+	}{
+		{"unknown field", `{"jsonrpc":"2.0","method":"add","params":{"c":1},"id":1}`, nil, RPCInvalidParams, "Invalid params"},
+		{"validation", `{"jsonrpc":"2.0","method":"add","params":{"b":101},"id":1}`, nil, RPCInvalidParams, "Invalid params"},
+		{"http error", `{"jsonrpc":"2.0","method":"fail","params":{"Kind":"missing"},"id":1}`, nil, RPCServerError, "no such user"},
+		{"internal error", `{"jsonrpc":"2.0","method":"fail","params":{},"id":1}`, nil, RPCInternalError, "Internal error"},
+		{"middleware", `{"jsonrpc":"2.0","method":"admin.reset","id":1}`, nil, RPCServerError, "admin only"},
+		{"middleware passed", `{"jsonrpc":"2.0","method":"admin.reset","id":1}`, []string{"Authorization", "Bearer admin"}, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := postRPC(router, test.body, test.header...)
+
+			var response struct {
+				Result json.RawMessage
+				Error  *RPCError
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			switch {
+			case test.code == 0 && response.Error != nil:
+				t.Errorf("Expected a result, got %+v", response.Error)
+			case test.code != 0 && (response.Error == nil || response.Error.Code != test.code || response.Error.Message != test.message):
+				t.Errorf("Expected error %d %q, got %s", test.code, test.message, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "leaked") {
+				t.Error("Expected internal error messages to be hidden")
+			}
+		})
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	router, notified := newTestRPC(t)
+
+	w := postRPC(router, `[
+		{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":1},"id":1},
+		{"jsonrpc":"2.0","method":"notify","params":{"Event":"created"}},
+		1
+	]`)
+	expected := `[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`
+	if strings.TrimSpace(w.Body.String()) != expected {
+		t.Errorf("Expected %s, got %s", expected, w.Body.String())
+	}
+	if len(*notified) != 1 || (*notified)[0] != "created" {
+		t.Errorf("Expected the notification to run, got %v", *notified)
+	}
+
+	w = postRPC(router, `[{"jsonrpc":"2.0","method":"notify","params":{"Event":"a"}},{"jsonrpc":"2.0","method":"notify","params":{"Event":"b"}}]`)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("Expected no response to notifications, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = postRPC(router, `[{"jsonrpc":"2.0","method":"sum","id":1},{"jsonrpc":"2.0","method":"sum","id":2},{"jsonrpc":"2.0","method":"sum","id":3},{"jsonrpc":"2.0","method":"sum","id":4}]`)
+	if !strings.Contains(w.Body.String(), `"code":-32600`) {
+		t.Errorf("Expected oversized batches to be rejected, got %s", w.Body.String())
+	}
+}
+
+func TestRPCTransport(t *testing.T) {
+	router, _ := newTestRPC(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/rpc", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected only POST, got %d", w.Code)
+	}
+
+	w = postRPC(router, `{}`, "Content-Type", "text/plain")
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a JSON body, got %d", w.Code)
+	}
+
+	rpc := NewRPCServer(RPCOptions{})
+	HandleRPC(rpc, "ping", func(ctx context.Context, p struct{}) (string, error) { return "pong", nil })
+	mounted := NewRouter()
+	mounted.With(MountExact()).Mount("/rpc", rpc)
+	if body := postRPC(mounted, `{"jsonrpc":"2.0","method":"ping","id":1}`).Body.String(); !strings.Contains(body, `"pong"`) {
+		t.Errorf("Expected the mounted server to answer, got %s", body)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a method twice to panic")
+		}
+	}()
+	HandleRPC(rpc, "ping", func(ctx context.Context, p struct{}) (string, error) { return "", nil })
+}
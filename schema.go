@@ -0,0 +1,329 @@
+package simplerouter
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1 documents. It covers the
+// keywords the router generates and validates.
+type Schema struct {
+	Ref         string      `json:"$ref,omitempty"`
+	Type        SchemaTypes `json:"type,omitempty"`
+	Format      string      `json:"format,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	Enum    []any    `json:"enum,omitempty"`
+	Const   any      `json:"const,omitempty"`
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// ExclusiveMinimum and ExclusiveMaximum are read from the boolean form of
+	// OpenAPI 3.0 too.
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
+	MinItems         *int     `json:"minItems,omitempty"`
+	MaxItems         *int     `json:"maxItems,omitempty"`
+	Pattern          string   `json:"pattern,omitempty"`
+
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	AllOf []*Schema `json:"allOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+
+	// Nullable is the OpenAPI 3.0 spelling of a "null" type.
+	Nullable        bool   `json:"nullable,omitempty"`
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	Default         any    `json:"default,omitempty"`
+	Example         any    `json:"example,omitempty"`
+	Examples        []any  `json:"examples,omitempty"`
+}
+
+// UnmarshalJSON also accepts the boolean schemas true, which allows anything,
+// and false, which allows nothing.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{Not: &Schema{}}
+		return nil
+	}
+
+	type schema Schema
+	aux := struct {
+		*schema
+		ExclusiveMinimum json.RawMessage `json:"exclusiveMinimum"`
+		ExclusiveMaximum json.RawMessage `json:"exclusiveMaximum"`
+	}{schema: (*schema)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	s.ExclusiveMinimum, s.Minimum, err = exclusiveBound(aux.ExclusiveMinimum, s.Minimum)
+	if err != nil {
+		return err
+	}
+	s.ExclusiveMaximum, s.Maximum, err = exclusiveBound(aux.ExclusiveMaximum, s.Maximum)
+	return err
+}
+
+// exclusiveBound reads an exclusive bound, which OpenAPI 3.0 writes as a
+// boolean turning the inclusive bound exclusive.
+func exclusiveBound(raw json.RawMessage, inclusive *float64) (exclusive, remaining *float64, err error) {
+	switch string(bytes.TrimSpace(raw)) {
+	case "", "null", "false":
+		return nil, inclusive, nil
+	case "true":
+		return inclusive, nil, nil
+	}
+
+	var bound float64
+	if err := json.Unmarshal(raw, &bound); err != nil {
+		return nil, nil, err
+	}
+	return &bound, inclusive, nil
+}
+
+// SchemaTypes is the type keyword of a schema, a single type or a list of them.
+type SchemaTypes []string
+
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether the type list includes name.
+func (t SchemaTypes) Has(name string) bool {
+	for _, typ := range t {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaGenerator builds schemas for Go types, collecting named struct types
+// as shared components.
+type schemaGenerator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	componentNameChar = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// schema returns the schema of values of t as encoding/json writes them.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaTypes{"string"}, Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if len(schema.Type) == 1 {
+			schema.Type = append(schema.Type, "null")
+		}
+		return schema
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: SchemaTypes{"string"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypes{"boolean"}}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: SchemaTypes{"integer"}, Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: SchemaTypes{"integer"}, Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		minimum := 0.0
+		return &Schema{Type: SchemaTypes{"integer"}, Minimum: &minimum}
+	case reflect.Float32:
+		return &Schema{Type: SchemaTypes{"number"}, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: SchemaTypes{"number"}, Format: "double"}
+	case reflect.String:
+		return &Schema{Type: SchemaTypes{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: SchemaTypes{"string"}, ContentEncoding: "base64"}
+		}
+		return &Schema{Type: SchemaTypes{"array"}, Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: SchemaTypes{"object"}, AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+
+	// Interfaces, and types JSON cannot encode, may hold anything.
+	return &Schema{}
+}
+
+// structRef returns a reference to the component of a named struct type, or
+// the inline schema of an anonymous one.
+func (g *schemaGenerator) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		// Register the name first so recursive types refer to themselves.
+		g.components[name] = &Schema{}
+		*g.components[name] = *g.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := componentNameChar.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	name = componentNameChar.ReplaceAllString(pkg, "_") + "." + name
+	for candidate, i := name, 2; ; i++ {
+		if _, taken := g.components[candidate]; !taken {
+			return candidate
+		}
+		candidate = name + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: SchemaTypes{"object"}, Properties: map[string]*Schema{}}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			// Fields bound from elsewhere in the request are not part of the
+			// body unless they are named for it.
+			if source, _ := validateFieldName(field); source != "body" {
+				continue
+			}
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		if strings.Contains(options, "string") && property.Ref == "" {
+			property.Type = SchemaTypes{"string"}
+		}
+		if rules, err := parseRules(field); err == nil {
+			if property.Ref != "" && len(rules) > 0 {
+				property = &Schema{AllOf: []*Schema{property}}
+			}
+			if applySchemaRules(property, rules) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applySchemaRules adds the constraints of validate and pattern tags to a
+// schema, and reports whether the field is required.
+func applySchemaRules(schema *Schema, rules []validateRule) (required bool) {
+	isString := schema.Type.Has("string")
+	isArray := schema.Type.Has("array") || schema.Type.Has("object")
+
+	for _, rule := range rules {
+		limit := rule.limit
+		count := int(limit)
+
+		switch {
+		case rule.name == "required":
+			required = true
+		case rule.name == "email":
+			schema.Format = "email"
+		case rule.name == "uuid":
+			schema.Format = "uuid"
+		case rule.name == "pattern":
+			schema.Pattern = rule.arg
+		case rule.name == "oneof":
+			for _, value := range strings.Fields(rule.arg) {
+				var typed any = value
+				if !isString {
+					json.Unmarshal([]byte(value), &typed)
+				}
+				schema.Enum = append(schema.Enum, typed)
+			}
+		case isString && (rule.name == "min" || rule.name == "len"):
+			schema.MinLength = &count
+			if rule.name == "len" {
+				schema.MaxLength = &count
+			}
+		case isString && rule.name == "max":
+			schema.MaxLength = &count
+		case isArray && (rule.name == "min" || rule.name == "len"):
+			schema.MinItems = &count
+			if rule.name == "len" {
+				schema.MaxItems = &count
+			}
+		case isArray && rule.name == "max":
+			schema.MaxItems = &count
+		case rule.name == "min":
+			schema.Minimum = &limit
+		case rule.name == "max":
+			schema.Maximum = &limit
+		}
+	}
+	return required
+}
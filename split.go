@@ -0,0 +1,248 @@
+package simplerouter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SplitVariant is one of the handlers a Split chooses between.
+type SplitVariant struct {
+	Name    string
+	Weight  int
+	Handler http.Handler
+}
+
+// SplitOptions configures how a Split assigns requests to variants. The zero
+// value assigns every request at random.
+type SplitOptions struct {
+	// Cookie, if set, names a cookie remembering the variant assigned to a
+	// client.
+	Cookie string
+	// CookieMaxAge is the lifetime of the cookie. Defaults to 30 days.
+	CookieMaxAge time.Duration
+	// Header, if set, names a request header, such as X-User-ID, whose value
+	// is hashed to assign the variant.
+	Header string
+	// Key, if set, returns the value hashed to assign the variant, such as
+	// the ID of the authenticated user. It takes precedence over Header; an
+	// empty key falls back to Header, then to random assignment.
+	Key func(*http.Request) string
+	// Override, if set, names a request header choosing the variant by name,
+	// regardless of weights, for testing.
+	Override string
+
+	random func(n int) int
+}
+
+// SplitDecision records the variant a Split chose for a request.
+type SplitDecision struct {
+	Split   string `json:"split"`
+	Variant string `json:"variant"`
+	// Reason is how the variant was chosen: "override", "cookie", "key" or
+	// "random".
+	Reason string `json:"reason"`
+}
+
+// Split is a handler dividing the requests of a route between variants in
+// proportion to their weights, such as a stable and a canary release:
+//
+//	checkout := NewSplit("checkout", SplitOptions{Cookie: "checkout-variant"},
+//		SplitVariant{Name: "stable", Weight: 95, Handler: stable},
+//		SplitVariant{Name: "canary", Weight: 5, Handler: canary},
+//	)
+//	r.Post("/checkout", checkout.ServeHTTP)
+//
+// Clients with a cookie or key keep their variant while weights are
+// unchanged. Weights may be changed at runtime with SetWeights.
+type Split struct {
+	name string
+	opts SplitOptions
+
+	mu       sync.RWMutex
+	variants []SplitVariant
+}
+
+// NewSplit returns a Split identified by name in decisions and logs. It panics
+// if variants are missing, unnamed, duplicated, without handlers, or if their
+// weights are invalid.
+func NewSplit(name string, opts SplitOptions, variants ...SplitVariant) *Split {
+	if len(variants) == 0 {
+		panic("simplerouter: split " + name + " has no variants")
+	}
+	seen := map[string]bool{}
+	for _, variant := range variants {
+		if variant.Name == "" || variant.Handler == nil {
+			panic("simplerouter: split " + name + " has a variant without a name or handler")
+		}
+		if seen[variant.Name] {
+			panic("simplerouter: split " + name + " has duplicate variant " + variant.Name)
+		}
+		seen[variant.Name] = true
+	}
+	if err := checkSplitWeights(variants); err != nil {
+		panic("simplerouter: split " + name + ": " + err.Error())
+	}
+	if opts.CookieMaxAge <= 0 {
+		opts.CookieMaxAge = 30 * 24 * time.Hour
+	}
+	if opts.random == nil {
+		opts.random = rand.IntN
+	}
+
+	return &Split{name: name, opts: opts, variants: append([]SplitVariant(nil), variants...)}
+}
+
+func checkSplitWeights(variants []SplitVariant) error {
+	total := 0
+	for _, variant := range variants {
+		if variant.Weight < 0 {
+			return fmt.Errorf("variant %s has negative weight %d", variant.Name, variant.Weight)
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("no variant has a positive weight")
+	}
+	return nil
+}
+
+func (s *Split) Name() string {
+	return s.name
+}
+
+// Weights returns the current weight of each variant.
+func (s *Split) Weights() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	weights := make(map[string]int, len(s.variants))
+	for _, variant := range s.variants {
+		weights[variant.Name] = variant.Weight
+	}
+	return weights
+}
+
+// SetWeights changes the weights of the named variants, leaving the others
+// as they are. Clients assigned by cookie to a variant whose weight drops to
+// zero are reassigned.
+func (s *Split) SetWeights(weights map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	variants := append([]SplitVariant(nil), s.variants...)
+	for name, weight := range weights {
+		i := s.index(name)
+		if i < 0 {
+			return fmt.Errorf("split %s: unknown variant %s", s.name, name)
+		}
+		variants[i].Weight = weight
+	}
+	if err := checkSplitWeights(variants); err != nil {
+		return fmt.Errorf("split %s: %w", s.name, err)
+	}
+
+	s.variants = variants
+	logger.Debug("Split weights changed", "split", s.name, "weights", weights)
+	return nil
+}
+
+// index returns the position of the named variant, or -1. s.mu must be held.
+func (s *Split) index(name string) int {
+	for i, variant := range s.variants {
+		if variant.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+type splitContextKey struct{}
+
+// SplitFromContext returns the decision of the innermost Split that served
+// the request.
+func SplitFromContext(ctx context.Context) (SplitDecision, bool) {
+	decision, ok := ctx.Value(splitContextKey{}).(SplitDecision)
+	return decision, ok
+}
+
+func (s *Split) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	variant, reason := s.choose(r)
+	decision := SplitDecision{Split: s.name, Variant: variant.Name, Reason: reason}
+	logger.Debug("Split", "split", s.name, "variant", variant.Name, "reason", reason, "path", r.URL.Path)
+
+	if s.opts.Cookie != "" && reason != "cookie" && reason != "override" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     s.opts.Cookie,
+			Value:    variant.Name,
+			Path:     "/",
+			MaxAge:   int(s.opts.CookieMaxAge / time.Second),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	variant.Handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), splitContextKey{}, decision)))
+}
+
+// choose picks the variant of a request and says why.
+func (s *Split) choose(r *http.Request) (SplitVariant, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.opts.Override != "" {
+		if i := s.index(r.Header.Get(s.opts.Override)); i >= 0 {
+			return s.variants[i], "override"
+		}
+	}
+	if s.opts.Cookie != "" {
+		if cookie, err := r.Cookie(s.opts.Cookie); err == nil {
+			if i := s.index(cookie.Value); i >= 0 && s.variants[i].Weight > 0 {
+				return s.variants[i], "cookie"
+			}
+		}
+	}
+
+	total := 0
+	for _, variant := range s.variants {
+		total += variant.Weight
+	}
+
+	if key := s.key(r); key != "" {
+		hash := fnv.New64a()
+		hash.Write([]byte(s.name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+		return s.pick(int(hash.Sum64() % uint64(total))), "key"
+	}
+	return s.pick(s.opts.random(total)), "random"
+}
+
+func (s *Split) key(r *http.Request) string {
+	if s.opts.Key != nil {
+		if key := s.opts.Key(r); key != "" {
+			return key
+		}
+	}
+	if s.opts.Header != "" {
+		return r.Header.Get(s.opts.Header)
+	}
+	return ""
+}
+
+// pick returns the variant whose share of the total weight contains n.
+// s.mu must be held.
+func (s *Split) pick(n int) SplitVariant {
+	for _, variant := range s.variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+	return s.variants[len(s.variants)-1]
+}
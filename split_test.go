@@ -0,0 +1,155 @@
+package simplerouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func variantHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ := SplitFromContext(r.Context())
+		fmt.Fprintf(w, "%s %s %s", name, decision.Variant, decision.Reason)
+	})
+}
+
+func newTestSplit(opts SplitOptions) *Split {
+	return NewSplit("checkout", opts,
+		SplitVariant{Name: "stable", Weight: 95, Handler: variantHandler("stable")},
+		SplitVariant{Name: "canary", Weight: 5, Handler: variantHandler("canary")},
+	)
+}
+
+func serveSplit(split *Split, setup func(r *http.Request)) *httptest.ResponseRecorder {
+	router := NewRouter()
+	router.Post("/checkout", split.ServeHTTP)
+
+	r := httptest.NewRequest("POST", "/checkout", nil)
+	if setup != nil {
+		setup(r)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestSplitWeights(t *testing.T) {
+	var n int
+	opts := SplitOptions{}
+	opts.random = func(total int) int {
+		if total != 100 {
+			t.Errorf("Expected the total weight, got %d", total)
+		}
+		return n
+	}
+	split := newTestSplit(opts)
+
+	for value, expected := range map[int]string{0: "stable", 94: "stable", 95: "canary", 99: "canary"} {
+		n = value
+		if body := serveSplit(split, nil).Body.String(); body != expected+" "+expected+" random" {
+			t.Errorf("Expected %d to pick %s, got %q", value, expected, body)
+		}
+	}
+}
+
+func TestSplitCookie(t *testing.T) {
+	opts := SplitOptions{Cookie: "checkout-variant"}
+	opts.random = func(int) int { return 99 }
+	split := newTestSplit(opts)
+
+	w := serveSplit(split, nil)
+	cookies := w.Result().Cookies()
+	if w.Body.String() != "canary canary random" || len(cookies) != 1 || cookies[0].Value != "canary" {
+		t.Fatalf("Expected the assignment to be remembered, got %q %v", w.Body.String(), cookies)
+	}
+
+	w = serveSplit(split, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "checkout-variant", Value: "stable"}) })
+	if w.Body.String() != "stable stable cookie" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected the cookie to be honored, got %q", w.Body.String())
+	}
+
+	if err := split.SetWeights(map[string]int{"stable": 0}); err != nil {
+		t.Fatal(err)
+	}
+	w = serveSplit(split, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "checkout-variant", Value: "stable"}) })
+	if w.Body.String() != "canary canary random" || len(w.Result().Cookies()) != 1 {
+		t.Errorf("Expected a client of a withdrawn variant to be reassigned, got %q", w.Body.String())
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	split := newTestSplit(SplitOptions{Header: "X-User-ID"})
+
+	counts := map[string]int{}
+	for i := range 1000 {
+		user := fmt.Sprint("user-", i)
+		first := serveSplit(split, func(r *http.Request) { r.Header.Set("X-User-ID", user) }).Body.String()
+		again := serveSplit(split, func(r *http.Request) { r.Header.Set("X-User-ID", user) }).Body.String()
+		if first != again {
+			t.Fatalf("Expected %s to keep its variant, got %q then %q", user, first, again)
+		}
+		counts[first]++
+	}
+	if canary := counts["canary canary key"]; canary < 20 || canary > 90 {
+		t.Errorf("Expected about 5%% of users on the canary, got %v", counts)
+	}
+
+	keyed := newTestSplit(SplitOptions{Header: "X-User-ID", Key: func(r *http.Request) string { return "" }})
+	keyed.opts.random = func(int) int { return 0 }
+	if body := serveSplit(keyed, nil).Body.String(); body != "stable stable random" {
+		t.Errorf("Expected requests without a key to be assigned at random, got %q", body)
+	}
+}
+
+func TestSplitOverride(t *testing.T) {
+	split := newTestSplit(SplitOptions{Override: "X-Variant", Cookie: "checkout-variant"})
+	split.SetWeights(map[string]int{"canary": 0})
+
+	w := serveSplit(split, func(r *http.Request) { r.Header.Set("X-Variant", "canary") })
+	if w.Body.String() != "canary canary override" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected the override to win without being remembered, got %q", w.Body.String())
+	}
+
+	w = serveSplit(split, func(r *http.Request) { r.Header.Set("X-Variant", "unknown") })
+	if w.Body.String() != "stable stable random" {
+		t.Errorf("Expected unknown variants to be ignored, got %q", w.Body.String())
+	}
+}
+
+func TestSplitSetWeights(t *testing.T) {
+	split := newTestSplit(SplitOptions{})
+
+	for _, weights := range []map[string]int{{"beta": 1}, {"stable": -1}, {"stable": 0, "canary": 0}} {
+		if err := split.SetWeights(weights); err == nil {
+			t.Errorf("Expected %v to be rejected", weights)
+		}
+	}
+	if weights := split.Weights(); weights["stable"] != 95 || weights["canary"] != 5 {
+		t.Errorf("Expected rejected weights to be left alone, got %v", weights)
+	}
+
+	split.SetWeights(map[string]int{"stable": 50, "canary": 50})
+	if weights := split.Weights(); weights["stable"] != 50 || weights["canary"] != 50 {
+		t.Errorf("Expected the weights to change, got %v", weights)
+	}
+}
+
+func TestNewSplitInvalid(t *testing.T) {
+	handler := http.NotFoundHandler()
+	for name, variants := range map[string][]SplitVariant{
+		"none":       nil,
+		"unnamed":    {{Weight: 1, Handler: handler}},
+		"duplicate":  {{Name: "a", Weight: 1, Handler: handler}, {Name: "a", Weight: 1, Handler: handler}},
+		"weightless": {{Name: "a", Handler: handler}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s variants to panic", name)
+				}
+			}()
+			NewSplit("invalid", SplitOptions{}, variants...)
+		}()
+	}
+}
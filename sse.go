@@ -0,0 +1,347 @@
+package simplerouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID, if set, is remembered by the client and sent back in the
+	// Last-Event-ID header when it reconnects.
+	ID string
+	// Event is the event type; clients dispatch events without one as
+	// "message".
+	Event string
+	// Data is the payload; it may span several lines.
+	Data string
+	// Retry, if set, tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+var errInvalidEvent = errors.New("sse: event id and type must not contain line breaks")
+
+func checkEvent(event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") || strings.ContainsAny(event.Event, "\r\n") {
+		return errInvalidEvent
+	}
+	return nil
+}
+
+// appendEvent frames event in the text/event-stream format.
+func appendEvent(b []byte, event Event) ([]byte, error) {
+	if err := checkEvent(event); err != nil {
+		return b, err
+	}
+
+	if event.ID != "" {
+		b = append(b, "id: "...)
+		b = append(b, event.ID...)
+		b = append(b, '\n')
+	}
+	if event.Event != "" {
+		b = append(b, "event: "...)
+		b = append(b, event.Event...)
+		b = append(b, '\n')
+	}
+	if event.Retry > 0 {
+		b = append(b, "retry: "...)
+		b = strconv.AppendInt(b, event.Retry.Milliseconds(), 10)
+		b = append(b, '\n')
+	}
+
+	data := strings.ReplaceAll(event.Data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b = append(b, "data: "...)
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+	return append(b, '\n'), nil
+}
+
+// ReplayBuffer keeps recent events so that reconnecting clients can resume
+// from the Last-Event-ID they send. Every stream sending an event with an ID
+// appends it, so implementations must ignore events whose ID they already
+// hold.
+type ReplayBuffer interface {
+	Append(ctx context.Context, event Event) error
+	// Since returns the events after the one with the given ID, oldest
+	// first, and whether that ID is still held.
+	Since(ctx context.Context, id string) ([]Event, bool, error)
+}
+
+// MemoryReplay is a ReplayBuffer holding the most recent events in memory.
+type MemoryReplay struct {
+	mu     sync.Mutex
+	events []Event
+	size   int
+}
+
+// NewMemoryReplay returns a MemoryReplay holding up to size events.
+func NewMemoryReplay(size int) *MemoryReplay {
+	if size <= 0 {
+		size = 100
+	}
+	return &MemoryReplay{size: size}
+}
+
+func (m *MemoryReplay) Append(ctx context.Context, event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].ID == event.ID {
+			return nil
+		}
+	}
+	if len(m.events) == m.size {
+		m.events = append(m.events[:0], m.events[1:]...)
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MemoryReplay) Since(ctx context.Context, id string) ([]Event, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].ID == id {
+			return append([]Event(nil), m.events[i+1:]...), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// SSEOptions configures an EventStream. The zero value is usable.
+type SSEOptions struct {
+	// KeepAlive is the interval of the comments sent to keep idle
+	// connections open through proxies. Defaults to 15 seconds; negative
+	// values disable them.
+	KeepAlive time.Duration
+	// Retry, if set, is sent to the client as its reconnection delay when the
+	// stream opens.
+	Retry time.Duration
+	// Replay, if set, remembers the events sent with an ID and replays those
+	// a reconnecting client missed.
+	Replay ReplayBuffer
+}
+
+// EventStream writes server-sent events to a client. Its methods are safe for
+// concurrent use.
+type EventStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	opts    SSEOptions
+	lastID  string
+	resumed bool
+
+	mu     sync.Mutex
+	buf    []byte
+	err    error
+	closed bool
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewEventStream starts an event stream in response to r: it writes the
+// headers, replays the events missed since the Last-Event-ID of r and starts
+// sending keepalive comments. The stream must be closed before the handler
+// returns. It fails if w cannot be flushed, after the headers were written.
+func NewEventStream(w http.ResponseWriter, r *http.Request, opts SSEOptions) (*EventStream, error) {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = 15 * time.Second
+	}
+
+	s := &EventStream{
+		ctx:    r.Context(),
+		w:      w,
+		rc:     http.NewResponseController(w),
+		opts:   opts,
+		lastID: r.Header.Get("Last-Event-ID"),
+		stop:   make(chan struct{}),
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if err := s.rc.Flush(); err != nil {
+		return nil, err
+	}
+	// Streams outlive the write timeout of the server.
+	s.rc.SetWriteDeadline(time.Time{})
+
+	if opts.Retry > 0 {
+		s.buf = append(s.buf, "retry: "...)
+		s.buf = strconv.AppendInt(s.buf, opts.Retry.Milliseconds(), 10)
+		s.buf = append(s.buf, "\n\n"...)
+	}
+	if opts.Replay != nil && s.lastID != "" {
+		events, ok, err := opts.Replay.Since(s.ctx, s.lastID)
+		if err != nil {
+			logger.Debug("Event replay failed", "lastEventID", s.lastID, "error", err)
+		}
+		s.resumed = ok
+		for _, event := range events {
+			if s.buf, err = appendEvent(s.buf, event); err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.mu.Lock()
+	err := s.flush()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.KeepAlive > 0 {
+		s.done.Add(1)
+		go s.keepAlive()
+	}
+	return s, nil
+}
+
+// LastEventID returns the Last-Event-ID sent by the client, if any.
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Resumed reports whether the events missed since LastEventID were replayed.
+// When it is false for a client sending a LastEventID, the client may have
+// missed events the replay buffer no longer holds.
+func (s *EventStream) Resumed() bool {
+	return s.resumed
+}
+
+// Done is closed when the client goes away.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes and flushes event, appending it to the replay buffer if it has
+// an ID. It fails once the client is gone or a write has failed.
+func (s *EventStream) Send(event Event) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkEvent(event); err != nil {
+		return err
+	}
+	if event.ID != "" && s.opts.Replay != nil {
+		if err := s.opts.Replay.Append(s.ctx, event); err != nil {
+			logger.Debug("Event replay append failed", "id", event.ID, "error", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.buf, err = appendEvent(s.buf, event); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// Comment writes and flushes a comment, which clients ignore.
+func (s *EventStream) Comment(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		s.buf = append(s.buf, ": "...)
+		s.buf = append(s.buf, line...)
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, '\n')
+	return s.flush()
+}
+
+// Close stops the keepalive comments. The stream must not be used
+// afterwards.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.done.Wait()
+}
+
+// flush writes the buffered bytes. s.mu must be held.
+func (s *EventStream) flush() error {
+	if s.err == nil && s.closed {
+		s.err = errors.New("sse: stream closed")
+	}
+	if s.err == nil && len(s.buf) > 0 {
+		if _, err := s.w.Write(s.buf); err != nil {
+			s.err = err
+		} else if err := s.rc.Flush(); err != nil {
+			s.err = err
+		}
+	}
+	s.buf = s.buf[:0]
+	return s.err
+}
+
+func (s *EventStream) keepAlive() {
+	defer s.done.Done()
+
+	ticker := time.NewTicker(s.opts.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.Comment("keepalive") != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// SSE returns a handler opening an event stream for each request and passing
+// it to fn, which sends events until it returns or the client goes away:
+//
+//	r.Get("/events", SSE(SSEOptions{Replay: replay}, func(r *http.Request, s *EventStream) error {
+//		for {
+//			select {
+//			case <-s.Done():
+//				return nil
+//			case order := <-orders:
+//				if err := s.Send(Event{ID: order.ID, Event: "order", Data: order.JSON}); err != nil {
+//					return err
+//				}
+//			}
+//		}
+//	}))
+func SSE(opts SSEOptions, fn func(r *http.Request, s *EventStream) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := NewEventStream(w, r, opts)
+		if err != nil {
+			logger.Debug("Event stream failed", "path", r.URL.Path, "error", err)
+			return
+		}
+		defer s.Close()
+
+		if err := fn(r, s); err != nil && r.Context().Err() == nil {
+			logger.Debug("Event stream ended", "path", r.URL.Path, "error", err)
+		}
+	}
+}
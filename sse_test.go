@@ -0,0 +1,136 @@
+package simplerouter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		expected string
+	}{
+		{"data", Event{Data: "hello"}, "data: hello\n\n"},
+		{"empty", Event{}, "data: \n\n"},
+		{"fields", Event{ID: "7", Event: "order", Data: "{}", Retry: 2 * time.Second}, "id: 7\nevent: order\nretry: 2000\ndata: {}\n\n"},
+		{"multi-line", Event{Data: "a\nb\r\nc\rd\n"}, "data: a\ndata: b\ndata: c\ndata: d\ndata: \n\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := appendEvent(nil, test.event)
+			if err != nil || string(b) != test.expected {
+				t.Errorf("Expected %q, got %q %v", test.expected, b, err)
+			}
+		})
+	}
+
+	for _, event := range []Event{{ID: "1\n2"}, {Event: "a\rb"}} {
+		if _, err := appendEvent(nil, event); err == nil {
+			t.Errorf("Expected %+v to be rejected", event)
+		}
+	}
+}
+
+func TestSSE(t *testing.T) {
+	replay := NewMemoryReplay(2)
+	router := NewRouter()
+	router.Get("/events", SSE(SSEOptions{Retry: time.Second, Replay: replay}, func(r *http.Request, s *EventStream) error {
+		if err := s.Comment(fmt.Sprintf("last %q resumed %t", s.LastEventID(), s.Resumed())); err != nil {
+			return err
+		}
+		for _, id := range []string{"1", "2", "3"} {
+			if err := s.Send(Event{ID: id, Event: "tick", Data: "tick " + id}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	if w.Header().Get("Content-Type") != "text/event-stream" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected event stream headers, got %v", w.Header())
+	}
+	expected := "retry: 1000\n\n: last \"\" resumed false\n\n" +
+		"id: 1\nevent: tick\ndata: tick 1\n\n" +
+		"id: 2\nevent: tick\ndata: tick 2\n\n" +
+		"id: 3\nevent: tick\ndata: tick 3\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Last-Event-ID", "2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if !strings.HasPrefix(w.Body.String(), "retry: 1000\n\nid: 3\nevent: tick\ndata: tick 3\n\n: last \"2\" resumed true\n\n") {
+		t.Errorf("Expected the missed event to be replayed, got %q", w.Body.String())
+	}
+
+	r.Header.Set("Last-Event-ID", "1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if !strings.Contains(w.Body.String(), `: last "1" resumed false`) {
+		t.Errorf("Expected an evicted ID not to be resumed, got %q", w.Body.String())
+	}
+}
+
+func TestMemoryReplay(t *testing.T) {
+	replay := NewMemoryReplay(3)
+	ctx := context.Background()
+	for _, id := range []string{"1", "2", "2", "3", "4"} {
+		replay.Append(ctx, Event{ID: id})
+	}
+
+	events, ok, _ := replay.Since(ctx, "2")
+	if !ok || len(events) != 2 || events[0].ID != "3" || events[1].ID != "4" {
+		t.Errorf("Expected the events after 2 without duplicates, got %v %t", events, ok)
+	}
+	if _, ok, _ := replay.Since(ctx, "1"); ok {
+		t.Error("Expected the oldest event to be evicted")
+	}
+}
+
+func TestSSEKeepAliveAndCancellation(t *testing.T) {
+	ended := make(chan error, 1)
+	server := httptest.NewServer(SSE(SSEOptions{KeepAlive: 10 * time.Millisecond}, func(r *http.Request, s *EventStream) error {
+		<-s.Done()
+		ended <- s.Send(Event{Data: "late"})
+		return nil
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": keepalive\n" {
+		t.Fatalf("Expected a keepalive comment, got %q %v", line, err)
+	}
+
+	cancel()
+	select {
+	case err := <-ended:
+		if err == nil {
+			t.Error("Expected sending to a departed client to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the handler to stop when the client went away")
+	}
+}
@@ -0,0 +1,287 @@
+package simplerouter
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StaticOptions configures Router.Static.
+type StaticOptions struct {
+	// Index is the file served for directories. Defaults to "index.html".
+	Index string
+	// SPA serves the root Index for paths matching no file whose last segment
+	// has no extension, so a single page application can route them on the
+	// client.
+	SPA bool
+	// Browse lists the contents of directories without an Index. Listings are
+	// disabled by default.
+	Browse bool
+	// MaxAge sets the Cache-Control max-age of files that are neither HTML
+	// nor hashed. Zero leaves them to revalidate on every use.
+	MaxAge time.Duration
+	// Hashed reports whether a file name carries a content hash, so the file
+	// can be cached forever. Defaults to names with a hash segment before the
+	// extension, such as app.3f9a1b2c.js or index-BkA3x9Zq.js.
+	Hashed func(name string) bool
+}
+
+// staticEncodings are the precompressed siblings Static looks for, in order
+// of preference on equal q-values.
+var staticEncodings = []struct{ coding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Static serves the files of fsys, such as an embed.FS or os.DirFS, under
+// prefix. The prefix, and the base path of the router, are stripped before
+// looking up files.
+//
+// Files with a .br or .gz sibling are served precompressed when the
+// Accept-Encoding header allows it. Hashed file names are cached as immutable,
+// HTML is always revalidated, and other files follow MaxAge. Only GET and HEAD
+// are served; other methods get a 405.
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	assets, _ := fs.Sub(dist, "dist")
+//	r.Static("/", assets, StaticOptions{SPA: true})
+func (r *Router) Static(prefix string, fsys fs.FS, opts StaticOptions, chain ...middleware) {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+	if opts.Hashed == nil {
+		opts.Hashed = isHashedName
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	s := &staticHandler{fsys: fsys, opts: opts, prefix: buildRootPath(r.mux.rootPath, prefix)}
+	r.handle(http.MethodGet, prefix+"/", s.ServeHTTP, chain)
+}
+
+type staticHandler struct {
+	fsys   fs.FS
+	opts   StaticOptions
+	prefix string
+}
+
+func (s *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, s.prefix)
+	if !ok {
+		writeError(w, r, http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+rest), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		writeError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	switch {
+	case err == nil && info.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			target := path.Base(r.URL.Path) + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		index := path.Join(name, s.opts.Index)
+		if _, err := fs.Stat(s.fsys, index); err == nil {
+			s.serveFile(w, r, index)
+			return
+		}
+		if s.opts.Browse {
+			s.browse(w, r, name)
+			return
+		}
+		writeError(w, r, http.StatusNotFound)
+	case err == nil:
+		s.serveFile(w, r, name)
+	case errors.Is(err, fs.ErrNotExist) && s.opts.SPA && path.Ext(name) == "":
+		s.serveFile(w, r, s.opts.Index)
+	case errors.Is(err, fs.ErrNotExist):
+		writeError(w, r, http.StatusNotFound)
+	default:
+		WriteError(w, r, err)
+	}
+}
+
+// serveFile writes the file name, or its precompressed sibling, with the
+// cache headers for its kind.
+func (s *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	header := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+
+	switch {
+	case strings.HasPrefix(contentType, "text/html"):
+		header.Set("Cache-Control", "no-cache")
+	case s.opts.Hashed(path.Base(name)):
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	case s.opts.MaxAge > 0:
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.opts.MaxAge.Seconds())))
+	default:
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	served, coding := name, ""
+	if contentType != "" {
+		// Only known types are served precompressed, as sniffing the type of
+		// a compressed body would fail. Ranges are served from the original
+		// file, since clients resuming a download expect offsets into it.
+		header.Add("Vary", "Accept-Encoding")
+		if r.Header.Get("Range") == "" {
+			served, coding = s.precompressed(r.Header.Get("Accept-Encoding"), name)
+		}
+	}
+
+	file, err := s.fsys.Open(served)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if coding != "" {
+		header.Set("Content-Encoding", coding)
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// precompressed returns the sibling of name encoded in the coding the
+// Accept-Encoding header prefers, or name itself.
+func (s *staticHandler) precompressed(acceptEncoding, name string) (string, string) {
+	if acceptEncoding == "" {
+		return name, ""
+	}
+
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, q := parseQuality(part)
+		if coding != "" {
+			weights[coding] = q
+		}
+	}
+
+	best, bestCoding, bestQ := name, "", 0.0
+	for _, encoding := range staticEncodings {
+		q, ok := weights[encoding.coding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q <= bestQ {
+			continue
+		}
+		if info, err := fs.Stat(s.fsys, name+encoding.ext); err == nil && !info.IsDir() {
+			best, bestCoding, bestQ = name+encoding.ext, encoding.coding, q
+		}
+	}
+	return best, bestCoding
+}
+
+// browse lists a directory, leaving out the precompressed siblings of files.
+func (s *staticHandler) browse(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if isPrecompressedSibling(entries, entryName) {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := (&url.URL{Path: entryName}).String()
+		b.WriteString("<a href=\"" + html.EscapeString(link) + "\">" + html.EscapeString(entryName) + "</a>\n")
+	}
+	b.WriteString("</pre>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, b.String())
+}
+
+func isPrecompressedSibling(entries []fs.DirEntry, name string) bool {
+	for _, encoding := range staticEncodings {
+		original, ok := strings.CutSuffix(name, encoding.ext)
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Name() == original {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var hashedName = regexp.MustCompile(`[.-]([0-9a-fA-F]{8,}|[A-Za-z0-9_-]{8})\.[A-Za-z0-9]+$`)
+
+// isHashedName reports whether a file name has a segment that looks like a
+// content hash before its extension: eight or more hex digits, or the eight
+// character base64url hashes of bundlers, which must mix in digits or
+// capitals to tell them from words.
+func isHashedName(name string) bool {
+	match := hashedName.FindStringSubmatch(name)
+	if match == nil {
+		return false
+	}
+	hash := match[1]
+	if isHex(hash) {
+		return strings.ContainsAny(hash, "0123456789")
+	}
+	return strings.ContainsAny(hash, "0123456789") && strings.ToLower(hash) != hash
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package simplerouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func staticFS() fstest.MapFS {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"index.html":                {Data: []byte("<h1>app</h1>"), ModTime: modified},
+		"app.3f9a1b2c.js":           {Data: []byte("console.log(1)"), ModTime: modified},
+		"app.3f9a1b2c.js.br":        {Data: []byte("brotli"), ModTime: modified},
+		"app.3f9a1b2c.js.gz":        {Data: []byte("gzip"), ModTime: modified},
+		"robots.txt":                {Data: []byte("User-agent: *"), ModTime: modified},
+		"docs/guide/index.html":     {Data: []byte("<h1>guide</h1>"), ModTime: modified},
+		"images/logo.svg":           {Data: []byte("<svg/>"), ModTime: modified},
+		"images/logo.svg.gz":        {Data: []byte("svg gzip"), ModTime: modified},
+		"images/<script>.png":       {Data: []byte("png"), ModTime: modified},
+		"images/icons/favicon.ico":  {Data: []byte("ico"), ModTime: modified},
+		"images/icons/README.notes": {Data: []byte("notes"), ModTime: modified},
+	}
+}
+
+func TestStatic(t *testing.T) {
+	router := NewRouter()
+	router.SetBasePath("/app")
+	router.Static("/assets/", staticFS(), StaticOptions{SPA: true, MaxAge: time.Hour})
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		acceptEncoding string
+		byteRange      string
+		status         int
+		body           string
+		cacheControl   string
+		encoding       string
+		location       string
+	}{
+		{
+			name:   "index",
+			target: "/app/assets/",
+			status: http.StatusOK, body: "<h1>app</h1>", cacheControl: "no-cache",
+		},
+		{
+			name:   "hashed file",
+			target: "/app/assets/app.3f9a1b2c.js",
+			status: http.StatusOK, body: "console.log(1)", cacheControl: "public, max-age=31536000, immutable",
+		},
+		{
+			name:   "brotli preferred",
+			target: "/app/assets/app.3f9a1b2c.js", acceptEncoding: "gzip, br",
+			status: http.StatusOK, body: "brotli", encoding: "br",
+		},
+		{
+			name:   "gzip by q-value",
+			target: "/app/assets/app.3f9a1b2c.js", acceptEncoding: "br;q=0.5, gzip",
+			status: http.StatusOK, body: "gzip", encoding: "gzip",
+		},
+		{
+			name:   "range of the original",
+			target: "/app/assets/app.3f9a1b2c.js", acceptEncoding: "gzip, br", byteRange: "bytes=0-6",
+			status: http.StatusPartialContent, body: "console",
+		},
+		{
+			name:   "refused encoding",
+			target: "/app/assets/images/logo.svg", acceptEncoding: "br, gzip;q=0",
+			status: http.StatusOK, body: "<svg/>",
+		},
+		{
+			name:   "max age",
+			target: "/app/assets/robots.txt",
+			status: http.StatusOK, body: "User-agent: *", cacheControl: "public, max-age=3600",
+		},
+		{
+			name:   "nested index",
+			target: "/app/assets/docs/guide/",
+			status: http.StatusOK, body: "<h1>guide</h1>",
+		},
+		{
+			name:   "directory redirect",
+			target: "/app/assets/docs/guide?page=2",
+			status: http.StatusMovedPermanently, location: "/app/assets/docs/guide/?page=2",
+		},
+		{
+			name:   "client route",
+			target: "/app/assets/settings/profile",
+			status: http.StatusOK, body: "<h1>app</h1>", cacheControl: "no-cache",
+		},
+		{
+			name:   "missing file",
+			target: "/app/assets/missing.js",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "no listing",
+			target: "/app/assets/images/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "traversal",
+			target: "/app/assets/../../etc/passwd",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "head",
+			method: "HEAD", target: "/app/assets/robots.txt",
+			status: http.StatusOK,
+		},
+		{
+			name:   "post",
+			method: "POST", target: "/app/assets/robots.txt",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			// Keep dot segments, as a client could send them.
+			r.URL.Path, r.URL.RawQuery, _ = strings.Cut(test.target, "?")
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			if test.byteRange != "" {
+				r.Header.Set("Range", test.byteRange)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if test.body != "" && w.Body.String() != test.body {
+				t.Errorf("Expected body %q, got %q", test.body, w.Body.String())
+			}
+			if test.cacheControl != "" && w.Header().Get("Cache-Control") != test.cacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", test.cacheControl, w.Header().Get("Cache-Control"))
+			}
+			if encoding := w.Header().Get("Content-Encoding"); encoding != test.encoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", test.encoding, encoding)
+			}
+			if test.encoding != "" && w.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+				t.Errorf("Expected the type of the original file, got %q", w.Header().Get("Content-Type"))
+			}
+			if test.location != "" && w.Header().Get("Location") != test.location {
+				t.Errorf("Expected a redirect to %q, got %q", test.location, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestStaticBrowse(t *testing.T) {
+	router := NewRouter()
+	router.Static("/", staticFS(), StaticOptions{Browse: true})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/images/", nil))
+
+	body := w.Body.String()
+	for _, expected := range []string{`<a href="icons/">icons/</a>`, `<a href="logo.svg">logo.svg</a>`, `&lt;script&gt;.png`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the listing to contain %q, got:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, "logo.svg.gz") {
+		t.Errorf("Expected precompressed siblings to be hidden, got:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/settings", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no SPA fallback, got %d", w.Code)
+	}
+}
+
+func TestIsHashedName(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"app.3f9a1b2c.js":                true,
+		"index-BkA3x9Zq.js":              true,
+		"chunk-vendors.1a2b3c4d5e6f.css": true,
+		"main-settings.js":               false,
+		"deadbeef.js":                    false,
+		"app.deadbeef.js":                false,
+		"jquery-3.7.1.min.js":            false,
+		"index.html":                     false,
+	} {
+		if isHashedName(name) != expected {
+			t.Errorf("Expected isHashedName(%q) to be %v", name, expected)
+		}
+	}
+}
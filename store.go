@@ -0,0 +1,124 @@
+package simplerouter
+
+import (
+	"context"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Store holds the counters of the RateLimit middleware. Its operations map
+// onto simple atomic commands so that it can be backed by a shared server such
+// as Redis (INCR with PEXPIRE, GET, and a compare-and-swap script).
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value of key, or zero when it is absent or expired.
+	Get(ctx context.Context, key string) (int64, error)
+	// Increment adds one to the value of key and returns the result. A key
+	// that did not exist is created with the given expiry.
+	Increment(ctx context.Context, key string, expiry time.Duration) (int64, error)
+	// CompareAndSwap stores value under key with the given expiry if key
+	// currently holds old, treating absent keys as holding zero, and reports
+	// whether the swap happened.
+	CompareAndSwap(ctx context.Context, key string, old, value int64, expiry time.Duration) (bool, error)
+}
+
+// MemoryStore is an in-process Store. Keys are spread over independently
+// locked shards to reduce contention, and expired entries are swept lazily.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards []memoryShard
+}
+
+type memoryShard struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	writes int
+}
+
+type memoryItem struct {
+	value   int64
+	expires time.Time
+}
+
+// NewMemoryStore returns a MemoryStore with the given number of shards, which
+// defaults to a multiple of GOMAXPROCS when zero.
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	store := &MemoryStore{seed: maphash.MakeSeed(), shards: make([]memoryShard, shards)}
+	for i := range store.shards {
+		store.shards[i].items = map[string]memoryItem{}
+	}
+	return store
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	return &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	item, ok := shard.load(key, time.Now())
+	if !ok {
+		return 0, nil
+	}
+	return item.value, nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, expiry time.Duration) (int64, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+	item, ok := shard.load(key, now)
+	if !ok {
+		item.expires = now.Add(expiry)
+	}
+	item.value++
+	shard.store(key, item, now)
+	return item.value, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, old, value int64, expiry time.Duration) (bool, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+	item, _ := shard.load(key, now)
+	if item.value != old {
+		return false, nil
+	}
+
+	shard.store(key, memoryItem{value: value, expires: now.Add(expiry)}, now)
+	return true, nil
+}
+
+func (shard *memoryShard) load(key string, now time.Time) (memoryItem, bool) {
+	item, ok := shard.items[key]
+	if !ok || !now.Before(item.expires) {
+		return memoryItem{}, false
+	}
+	return item, true
+}
+
+func (shard *memoryShard) store(key string, item memoryItem, now time.Time) {
+	shard.items[key] = item
+
+	shard.writes++
+	if shard.writes%1024 == 0 {
+		for k, v := range shard.items {
+			if !now.Before(v.expires) {
+				delete(shard.items, k)
+			}
+		}
+	}
+}
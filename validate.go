@@ -0,0 +1,316 @@
+package simplerouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Validator is implemented by request types with checks of their own. Validate
+// calls it after the tag rules; returning a *ValidationError adds field
+// errors, and any other error fails validation with its message as detail.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError lists the fields failing validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s %q: %s", field.Source, field.Name, field.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Problem returns the 422 response for the error.
+func (e *ValidationError) Problem() *Problem {
+	return NewProblem(http.StatusUnprocessableEntity, "The request failed validation.").With("errors", e.Fields)
+}
+
+// Validate checks the struct v, or the struct it points to, against the rules
+// in its validate and pattern tags:
+//
+//	type CreateUser struct {
+//		Name  string `json:"name" validate:"required,max=64"`
+//		Email string `json:"email" validate:"required,email"`
+//		Role  string `json:"role" validate:"omitempty,oneof=admin member"`
+//		Age   int    `json:"age" validate:"min=18"`
+//		Slug  string `json:"slug" validate:"omitempty" pattern:"^[a-z0-9-]+$"`
+//	}
+//
+// The rules are required (not the zero value), min and max (the value of
+// numbers, the length of strings, slices and maps), len (an exact length),
+// oneof (space separated values), email, uuid and omitempty. Rules other than
+// required skip nil pointers, and also zero values with omitempty, so min=1
+// rejects 0 and "" unless the field is optional that way. Nested structs are
+// validated too. Failing fields are reported together as a *ValidationError,
+// named by their Bind tag or JSON name.
+//
+// JSON and BindFunc handlers validate their requests automatically; other
+// handlers can call Validate and pass its error to WriteError.
+func Validate(v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	var validationErr ValidationError
+	if value.Kind() == reflect.Struct {
+		if err := validateStruct(value, "", &validationErr); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var fieldsErr *ValidationError
+			if !errors.As(err, &fieldsErr) {
+				if StatusOf(err) != http.StatusInternalServerError {
+					return err
+				}
+				return &Error{Status: http.StatusUnprocessableEntity, Message: err.Error(), Err: err}
+			}
+			validationErr.Fields = append(validationErr.Fields, fieldsErr.Fields...)
+		}
+	}
+
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
+	}
+	return nil
+}
+
+type validateRule struct {
+	name string
+	arg  string
+	// limit is the parsed argument of min, max and len.
+	limit float64
+	// pattern is the compiled pattern tag.
+	pattern *regexp.Regexp
+}
+
+type validateField struct {
+	index  int
+	source string
+	name   string
+	rules  []validateRule
+	// nested is set for struct fields, which are validated recursively.
+	nested bool
+}
+
+var validatePlans sync.Map
+
+func validatePlanFor(t reflect.Type) ([]validateField, error) {
+	if cached, ok := validatePlans.Load(t); ok {
+		return cached.([]validateField), nil
+	}
+
+	var fields []validateField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		source, name := validateFieldName(field)
+		rules, err := parseRules(field)
+		if err != nil {
+			return nil, err
+		}
+
+		elem := field.Type
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		nested := elem.Kind() == reflect.Struct && elem != timeType
+
+		if len(rules) > 0 || nested {
+			fields = append(fields, validateField{i, source, name, rules, nested})
+		}
+	}
+
+	cached, _ := validatePlans.LoadOrStore(t, fields)
+	return cached.([]validateField), nil
+}
+
+func validateFieldName(field reflect.StructField) (source, name string) {
+	for _, source := range bindSources {
+		if name, ok := field.Tag.Lookup(source); ok {
+			return source, name
+		}
+	}
+
+	name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		name = field.Name
+	}
+	return "body", name
+}
+
+func parseRules(field reflect.StructField) ([]validateRule, error) {
+	var rules []validateRule
+	for _, part := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		rule := validateRule{name: name, arg: arg}
+
+		switch name {
+		case "":
+			continue
+		case "required", "omitempty", "email", "uuid", "oneof":
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("simplerouter: invalid %s rule on field %s: %q", name, field.Name, arg)
+			}
+			rule.limit = limit
+		default:
+			return nil, fmt.Errorf("simplerouter: unknown validation rule %q on field %s", name, field.Name)
+		}
+		rules = append(rules, rule)
+	}
+
+	if pattern, ok := field.Tag.Lookup("pattern"); ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("simplerouter: invalid pattern on field %s: %w", field.Name, err)
+		}
+		rules = append(rules, validateRule{name: "pattern", arg: pattern, pattern: compiled})
+	}
+
+	return rules, nil
+}
+
+func validateStruct(value reflect.Value, prefix string, validationErr *ValidationError) error {
+	fields, err := validatePlanFor(value.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		fieldValue := value.Field(field.index)
+		name := field.name
+		if prefix != "" && field.source == "body" {
+			name = prefix + "." + name
+		}
+
+		if message := checkRules(fieldValue, field.rules); message != "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{field.source, name, message})
+			continue
+		}
+
+		if field.nested {
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if err := validateStruct(fieldValue, name, validationErr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkRules returns the message of the first rule value breaks, or "".
+func checkRules(value reflect.Value, rules []validateRule) string {
+	if value.IsZero() {
+		if hasRule(rules, "required") {
+			return "is required"
+		}
+		if value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface || hasRule(rules, "omitempty") {
+			return ""
+		}
+	}
+
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		switch rule.name {
+		case "min", "max", "len":
+			measure, ok := measureOf(value, rule.name)
+			if !ok {
+				return fmt.Sprintf("cannot apply %s to %s", rule.name, value.Type())
+			}
+			switch {
+			case rule.name == "min" && measure.n < rule.limit:
+				return fmt.Sprintf("must be at least %s%s", rule.arg, measure.unit)
+			case rule.name == "max" && measure.n > rule.limit:
+				return fmt.Sprintf("must be at most %s%s", rule.arg, measure.unit)
+			case rule.name == "len" && measure.n != rule.limit:
+				return fmt.Sprintf("must have a length of %s", rule.arg)
+			}
+		case "oneof":
+			if !slices.Contains(strings.Fields(rule.arg), fmt.Sprint(value.Interface())) {
+				return "must be one of " + strings.Join(strings.Fields(rule.arg), ", ")
+			}
+		case "email":
+			address, err := mail.ParseAddress(value.String())
+			if value.Kind() != reflect.String || err != nil || address.Address != value.String() {
+				return "must be an email address"
+			}
+		case "uuid":
+			if value.Kind() != reflect.String || !uuidPattern.MatchString(value.String()) {
+				return "must be a UUID"
+			}
+		case "pattern":
+			if value.Kind() != reflect.String || !rule.pattern.MatchString(value.String()) {
+				return "must match " + rule.arg
+			}
+		}
+	}
+	return ""
+}
+
+func hasRule(rules []validateRule, name string) bool {
+	return slices.ContainsFunc(rules, func(rule validateRule) bool { return rule.name == name })
+}
+
+type measure struct {
+	n    float64
+	unit string
+}
+
+// measureOf returns what min, max and len compare: the value of numbers and
+// the length of everything else.
+func measureOf(value reflect.Value, rule string) (measure, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return measure{float64(len([]rune(value.String()))), " characters"}, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return measure{float64(value.Len()), " items"}, true
+	}
+
+	if rule == "len" {
+		return measure{}, false
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return measure{float64(value.Int()), ""}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return measure{float64(value.Uint()), ""}, true
+	case reflect.Float32, reflect.Float64:
+		return measure{value.Float(), ""}, true
+	}
+	return measure{}, false
+}
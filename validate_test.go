@@ -0,0 +1,153 @@
+package simplerouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signup struct {
+	Name    string   `json:"name" validate:"required,max=8"`
+	Email   string   `json:"email" validate:"required,email"`
+	Role    string   `json:"role" validate:"omitempty,oneof=admin member"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Slug    string   `json:"slug" validate:"omitempty" pattern:"^[a-z-]+$"`
+	ID      string   `json:"id" validate:"omitempty,uuid"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Code    string   `json:"code" validate:"omitempty,len=4"`
+	Address *address `json:"address"`
+	Limit   int      `query:"limit" json:"-" validate:"max=100"`
+}
+
+func (s signup) Validate() error {
+	if s.Role == "admin" && s.Age < 21 {
+		return &ValidationError{Fields: []FieldError{{"body", "role", "admins must be at least 21"}}}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := signup{Name: "ada", Email: "ada@example.com", Role: "member", Age: 36}
+
+	tests := []struct {
+		name     string
+		modify   func(*signup)
+		expected []FieldError
+	}{
+		{"valid", func(s *signup) {}, nil},
+		{"required", func(s *signup) { s.Name, s.Email = "", "" }, []FieldError{
+			{"body", "name", "is required"},
+			{"body", "email", "is required"},
+		}},
+		{"max length", func(s *signup) { s.Name = "augustaada" }, []FieldError{{"body", "name", "must be at most 8 characters"}}},
+		{"email", func(s *signup) { s.Email = "Ada <ada@example.com>" }, []FieldError{{"body", "email", "must be an email address"}}},
+		{"oneof", func(s *signup) { s.Role = "owner" }, []FieldError{{"body", "role", "must be one of admin, member"}}},
+		{"min", func(s *signup) { s.Age = 12 }, []FieldError{{"body", "age", "must be at least 18"}}},
+		{"zero values", func(s *signup) { s.Age = 0 }, []FieldError{{"body", "age", "must be at least 18"}}},
+		{"pattern", func(s *signup) { s.Slug = "Ada" }, []FieldError{{"body", "slug", "must match ^[a-z-]+$"}}},
+		{"uuid", func(s *signup) { s.ID = "1234" }, []FieldError{{"body", "id", "must be a UUID"}}},
+		{"slice length", func(s *signup) { s.Tags = []string{"a", "b", "c"} }, []FieldError{{"body", "tags", "must be at most 2 items"}}},
+		{"exact length", func(s *signup) { s.Code = "123" }, []FieldError{{"body", "code", "must have a length of 4"}}},
+		{"nested", func(s *signup) { s.Address = &address{} }, []FieldError{{"body", "address.city", "is required"}}},
+		{"bound fields", func(s *signup) { s.Limit = 500 }, []FieldError{{"query", "limit", "must be at most 100"}}},
+		{"Validate method", func(s *signup) { s.Role, s.Age = "admin", 19 }, []FieldError{{"body", "role", "admins must be at least 21"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid
+			tt.modify(&in)
+
+			err := Validate(&in)
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a *ValidationError, got %v", err)
+			}
+
+			if !reflect.DeepEqual(validationErr.Fields, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, validationErr.Fields)
+			}
+		})
+	}
+
+	t.Run("empty strings", func(t *testing.T) {
+		var in struct {
+			Nick     string  `json:"nick" validate:"min=3"`
+			Nickname *string `json:"nickname" validate:"min=3"`
+		}
+		var validationErr *ValidationError
+		if err := Validate(&in); !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Name != "nick" {
+			t.Errorf("Expected only the empty string to fail, got %v", err)
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		var in struct {
+			Name string `validate:"requird"`
+		}
+		if err := Validate(&in); err == nil || StatusOf(err) != 500 {
+			t.Errorf("Expected an internal error for an unknown rule, got %v", err)
+		}
+	})
+}
+
+func TestValidateHandlers(t *testing.T) {
+	router := NewRouter()
+	router.Post("/signup", JSON(func(ctx context.Context, in signup) (signup, error) {
+		return in, nil
+	}))
+	router.Post("/plain", func(w http.ResponseWriter, r *http.Request) {
+		var in signup
+		if err := Bind(r, &in); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if err := Validate(in); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/signup", "/plain"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("POST", path+"?limit=1000", strings.NewReader(`{"name": "ada", "age": 3}`))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status 422, got %d", w.Code)
+			}
+
+			var body struct {
+				Errors []FieldError `json:"errors"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+
+			expected := []FieldError{
+				{"body", "email", "is required"},
+				{"body", "age", "must be at least 18"},
+				{"query", "limit", "must be at most 100"},
+			}
+			if !reflect.DeepEqual(body.Errors, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, body.Errors)
+			}
+		})
+	}
+}
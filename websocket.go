@@ -0,0 +1,703 @@
+package simplerouter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a WebSocket data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes, as defined by RFC 6455.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// closeTimeout bounds how long closing waits for the close frame of the peer.
+const closeTimeout = time.Second
+
+// CloseError is returned by WebSocketConn.ReadMessage once the connection is
+// closed, with the close code sent by the peer or, if the connection failed
+// on this side, the code sent to the peer.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// ErrWebSocketClosed is returned by writes once the connection is closing.
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// WebSocketOptions configures WebSocket connections. The zero value is
+// usable.
+type WebSocketOptions struct {
+	// Origins lists the origins, such as "https://app.example.com", allowed
+	// to connect besides the origin of the server; "*" allows any. Requests
+	// without an Origin header, which browsers always send, are allowed.
+	Origins []string
+	// CheckOrigin, if set, replaces the Origins check.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+	// MaxMessageSize is the largest message read, after decompression;
+	// larger messages close the connection with CloseMessageTooBig. Defaults
+	// to DefaultJSONBodyLimit.
+	MaxMessageSize int64
+	// Compression negotiates permessage-deflate with clients offering it.
+	Compression bool
+	// FragmentSize, if set, splits written messages into frames of at most
+	// this many bytes.
+	FragmentSize int
+	// PingInterval, if set, is the interval of the pings sent to the client.
+	// Reads then fail if nothing is received for twice the interval.
+	PingInterval time.Duration
+	// WriteTimeout bounds each frame write. Defaults to 10 seconds.
+	WriteTimeout time.Duration
+}
+
+// WebSocketConn is a WebSocket connection. One goroutine may read while
+// others write; writes are serialized.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	opts        WebSocketOptions
+	subprotocol string
+	compress    bool
+
+	readMu   sync.Mutex
+	readErr  error
+	received chan struct{}
+	recvOnce sync.Once
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+	pinger    sync.WaitGroup
+}
+
+// WebSocket registers a WebSocket endpoint at path. Each connection is passed
+// to fn and closed when fn returns, with CloseNormal, or CloseInternalError
+// if fn returns an error:
+//
+//	r.WebSocket("/chat", WebSocketOptions{Origins: []string{"https://app.example.com"}},
+//		func(r *http.Request, conn *WebSocketConn) error {
+//			for {
+//				kind, message, err := conn.ReadMessage()
+//				if err != nil {
+//					return nil
+//				}
+//				if err := conn.WriteMessage(kind, message); err != nil {
+//					return err
+//				}
+//			}
+//		})
+func (r *Router) WebSocket(path string, opts WebSocketOptions, fn func(r *http.Request, conn *WebSocketConn) error, chain ...middleware) {
+	r.handle(http.MethodGet, path, func(w http.ResponseWriter, req *http.Request) {
+		conn, err := UpgradeWebSocket(w, req, opts)
+		if err != nil {
+			return
+		}
+
+		if err := fn(req, conn); err != nil {
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) {
+				logger.Debug("WebSocket handler failed", "path", req.URL.Path, "error", err)
+				conn.Close(CloseInternalError, "")
+				return
+			}
+		}
+		conn.Close(CloseNormal, "")
+	}, chain)
+}
+
+// UpgradeWebSocket performs the WebSocket handshake in response to r. If the
+// request is not an acceptable handshake, it writes an error response and
+// returns the error.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, opts WebSocketOptions) (*WebSocketConn, error) {
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = DefaultJSONBodyLimit
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+
+	fail := func(err *Error) (*WebSocketConn, error) {
+		WriteError(w, r, err)
+		return nil, err
+	}
+	if r.Method != http.MethodGet || !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		return fail(NewError(http.StatusUpgradeRequired, "WebSocket upgrade required"))
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(NewError(http.StatusUpgradeRequired, "Unsupported WebSocket version"))
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(NewError(http.StatusBadRequest, "Invalid Sec-WebSocket-Key"))
+	}
+	if !websocketOriginAllowed(r, &opts) {
+		return fail(NewError(http.StatusForbidden, "Origin not allowed"))
+	}
+
+	subprotocol := negotiateSubprotocol(r.Header, opts.Subprotocols)
+	compress := opts.Compression && offersDeflate(r.Header)
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(&Error{Status: http.StatusInternalServerError, Err: err})
+	}
+	netConn.SetDeadline(time.Time{})
+
+	var response bytes.Buffer
+	response.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	response.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		response.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		response.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	response.WriteString("\r\n")
+
+	netConn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+	if _, err := netConn.Write(response.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetWriteDeadline(time.Time{})
+
+	c := &WebSocketConn{
+		conn:        netConn,
+		br:          brw.Reader,
+		opts:        opts,
+		subprotocol: subprotocol,
+		compress:    compress,
+		received:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if opts.PingInterval > 0 {
+		c.pinger.Add(1)
+		go c.ping()
+	}
+	logger.Debug("WebSocket connected", "path", r.URL.Path, "subprotocol", subprotocol, "compression", compress)
+	return c, nil
+}
+
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerHasToken reports whether the comma-separated values of the header
+// contain token, case-insensitively.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func websocketOriginAllowed(r *http.Request, opts *WebSocketOptions) bool {
+	if opts.CheckOrigin != nil {
+		return opts.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range opts.Origins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateSubprotocol returns the first supported subprotocol the client
+// offers.
+func negotiateSubprotocol(header http.Header, supported []string) string {
+	var offered []string
+	for _, value := range header.Values("Sec-WebSocket-Protocol") {
+		for _, part := range strings.Split(value, ",") {
+			offered = append(offered, strings.TrimSpace(part))
+		}
+	}
+	for _, protocol := range supported {
+		if slices.Contains(offered, protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// offersDeflate reports whether the client offers permessage-deflate with
+// parameters the server can accept. The server always resets its compression
+// context and cannot shrink its window, so offers limiting it are declined.
+func offersDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+	offers:
+		for _, offer := range strings.Split(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			for _, param := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				switch strings.TrimSpace(name) {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				case "server_max_window_bits":
+					if strings.Trim(strings.TrimSpace(value), `"`) != "15" {
+						continue offers
+					}
+				default:
+					continue offers
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the address of the client.
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the deadline of reads; a zero value means none.
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage reads the next data message, answering pings and assembling
+// fragments on the way. Once the connection is closed it returns a
+// *CloseError, or the error that broke it.
+func (c *WebSocketConn) ReadMessage() (MessageType, []byte, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	kind, message, err := c.readMessage()
+	if err != nil {
+		c.readErr = err
+		c.recvOnce.Do(func() { close(c.received) })
+	}
+	return kind, message, err
+}
+
+func (c *WebSocketConn) readMessage() (MessageType, []byte, error) {
+	var (
+		kind       MessageType
+		message    []byte
+		compressed bool
+	)
+	for {
+		if c.opts.PingInterval > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(2 * c.opts.PingInterval))
+		}
+
+		header, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		if header.opcode >= wsClose {
+			if err := c.handleControl(header); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+
+		switch {
+		case header.opcode == wsContinuation && kind == 0:
+			return 0, nil, c.fail(&CloseError{Code: CloseProtocolError, Reason: "unexpected continuation frame"})
+		case header.opcode != wsContinuation && kind != 0:
+			return 0, nil, c.fail(&CloseError{Code: CloseProtocolError, Reason: "expected continuation frame"})
+		case header.opcode != wsContinuation:
+			kind = MessageType(header.opcode)
+			compressed = header.rsv1
+		case header.rsv1:
+			return 0, nil, c.fail(&CloseError{Code: CloseProtocolError, Reason: "compressed continuation frame"})
+		}
+
+		if header.length > c.opts.MaxMessageSize-int64(len(message)) {
+			return 0, nil, c.fail(&CloseError{Code: CloseMessageTooBig, Reason: "message too big"})
+		}
+		message, err = c.readPayload(message, header)
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+		if !header.fin {
+			continue
+		}
+
+		if compressed {
+			if message, err = c.inflate(message); err != nil {
+				return 0, nil, c.fail(err)
+			}
+		}
+		if kind == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(&CloseError{Code: CloseInvalidPayload, Reason: "invalid UTF-8"})
+		}
+		return kind, message, nil
+	}
+}
+
+type wsFrameHeader struct {
+	fin    bool
+	rsv1   bool
+	opcode byte
+	length int64
+	mask   [4]byte
+}
+
+func (c *WebSocketConn) readFrameHeader() (wsFrameHeader, error) {
+	var header wsFrameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return header, err
+	}
+
+	header.fin = b[0]&0x80 != 0
+	header.rsv1 = b[0]&0x40 != 0
+	header.opcode = b[0] & 0x0F
+	masked := b[1]&0x80 != 0
+	header.length = int64(b[1] & 0x7F)
+
+	switch {
+	case b[0]&0x30 != 0, header.rsv1 && (!c.compress || header.opcode >= wsClose):
+		return header, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	case header.opcode > wsBinary && header.opcode < wsClose, header.opcode > wsPong:
+		return header, &CloseError{Code: CloseProtocolError, Reason: "unknown opcode"}
+	case !masked:
+		return header, &CloseError{Code: CloseProtocolError, Reason: "unmasked client frame"}
+	case header.opcode >= wsClose && (!header.fin || header.length > 125):
+		return header, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+
+	switch header.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return header, err
+		}
+		header.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return header, err
+		}
+		length := binary.BigEndian.Uint64(b[:8])
+		if length > 1<<63-1 {
+			return header, &CloseError{Code: CloseProtocolError, Reason: "invalid frame length"}
+		}
+		header.length = int64(length)
+	}
+
+	if _, err := io.ReadFull(c.br, header.mask[:]); err != nil {
+		return header, err
+	}
+	return header, nil
+}
+
+// readPayload appends the unmasked payload of a frame to b.
+func (c *WebSocketConn) readPayload(b []byte, header wsFrameHeader) ([]byte, error) {
+	start := len(b)
+	b = slices.Grow(b, int(header.length))[:start+int(header.length)]
+	if _, err := io.ReadFull(c.br, b[start:]); err != nil {
+		return nil, err
+	}
+	for i := range b[start:] {
+		b[start+i] ^= header.mask[i%4]
+	}
+	return b, nil
+}
+
+func (c *WebSocketConn) inflate(compressed []byte) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(compressed), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+	defer reader.Close()
+
+	message, err := io.ReadAll(io.LimitReader(reader, c.opts.MaxMessageSize+1))
+	if err != nil {
+		return nil, &CloseError{Code: CloseInvalidPayload, Reason: "invalid compressed message"}
+	}
+	if int64(len(message)) > c.opts.MaxMessageSize {
+		return nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+	return message, nil
+}
+
+// handleControl answers pings and close frames.
+func (c *WebSocketConn) handleControl(header wsFrameHeader) error {
+	payload, err := c.readPayload(nil, header)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	switch header.opcode {
+	case wsPing:
+		if err := c.writeFrame(wsPong, payload, true, false); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+			return c.fail(err)
+		}
+		return nil
+	case wsPong:
+		return nil
+	}
+
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(&CloseError{Code: CloseProtocolError, Reason: "invalid close frame"})
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+			return c.fail(&CloseError{Code: CloseProtocolError, Reason: "invalid close frame"})
+		}
+	}
+
+	// Echo the close code and drop the connection, as the peer will not
+	// send anything more.
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	c.writeClose(code, "")
+	c.shutdown()
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1014:
+		return code != 1004 && code != CloseNoStatus && code != CloseAbnormal
+	}
+	return false
+}
+
+// fail closes the connection after a read error, telling the peer why when
+// the error is a protocol violation.
+func (c *WebSocketConn) fail(err error) error {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		c.writeClose(closeErr.Code, closeErr.Reason)
+	} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = &CloseError{Code: CloseAbnormal}
+	}
+	c.shutdown()
+	return err
+}
+
+// WriteMessage writes a data message, compressed if permessage-deflate was
+// negotiated and fragmented according to FragmentSize.
+func (c *WebSocketConn) WriteMessage(kind MessageType, data []byte) error {
+	if kind != TextMessage && kind != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", kind)
+	}
+
+	compressed := false
+	if c.compress {
+		var buf bytes.Buffer
+		writer, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		writer.Write(data)
+		writer.Flush()
+		data = bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+		compressed = true
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	opcode := byte(kind)
+	for {
+		fragment := data
+		if c.opts.FragmentSize > 0 && len(fragment) > c.opts.FragmentSize {
+			fragment = fragment[:c.opts.FragmentSize]
+		}
+		data = data[len(fragment):]
+
+		if err := c.writeFrameLocked(opcode, fragment, len(data) == 0, compressed); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		opcode, compressed = wsContinuation, false
+	}
+}
+
+// Ping sends a ping, which the client answers with a pong.
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return errors.New("websocket: ping payload too long")
+	}
+	return c.writeFrame(wsPing, data, true, false)
+}
+
+func (c *WebSocketConn) ping() {
+	defer c.pinger.Done()
+
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.Ping(nil) != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte, fin, rsv1 bool) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrameLocked(opcode, payload, fin, rsv1)
+}
+
+// writeFrameLocked writes an unmasked frame. c.writeMu must be held.
+func (c *WebSocketConn) writeFrameLocked(opcode byte, payload []byte, fin, rsv1 bool) error {
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == wsClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	if rsv1 {
+		first |= 0x40
+	}
+	frame = append(frame, first)
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame, unless one was sent already.
+func (c *WebSocketConn) writeClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	return c.writeFrame(wsClose, append(payload, reason...), true, false)
+}
+
+// Close starts the closing handshake with code and reason, waits briefly for
+// the client to answer and closes the connection. It is safe to call while
+// another goroutine reads.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	err := c.writeClose(code, reason)
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	}
+
+	if c.readMu.TryLock() {
+		// Nobody is reading: read until the close frame of the client.
+		c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+		for c.readErr == nil {
+			_, _, c.readErr = c.readMessage()
+		}
+		c.readMu.Unlock()
+	} else {
+		select {
+		case <-c.received:
+		case <-time.After(closeTimeout):
+		}
+	}
+
+	c.shutdown()
+	c.pinger.Wait()
+	return err
+}
+
+// shutdown closes the underlying connection and stops pinging.
+func (c *WebSocketConn) shutdown() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+	c.recvOnce.Do(func() { close(c.received) })
+}
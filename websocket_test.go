@@ -0,0 +1,348 @@
+package simplerouter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client speaking raw frames.
+type wsClient struct {
+	t      *testing.T
+	conn   net.Conn
+	br     *bufio.Reader
+	header http.Header
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server, path string, header http.Header) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Write(conn)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected the upgrade, got %d %s", resp.StatusCode, body)
+	}
+	return &wsClient{t: t, conn: conn, br: br, header: resp.Header}
+}
+
+func (c *wsClient) send(opcode byte, payload []byte, fin, rsv1 bool) {
+	c.t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	if rsv1 {
+		first |= 0x40
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(len(payload)))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 0x80|127), uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) receive() (opcode byte, payload []byte, fin, rsv1 bool) {
+	c.t.Helper()
+	var b, extended [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		c.t.Fatal(err)
+	}
+	length := int(b[1] & 0x7F)
+	switch length {
+	case 126:
+		io.ReadFull(c.br, extended[:2])
+		length = int(binary.BigEndian.Uint16(extended[:2]))
+	case 127:
+		io.ReadFull(c.br, extended[:])
+		length = int(binary.BigEndian.Uint64(extended[:]))
+	}
+	if b[1]&0x80 != 0 {
+		c.t.Fatal("Expected server frames to be unmasked")
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return b[0] & 0x0F, payload, b[0]&0x80 != 0, b[0]&0x40 != 0
+}
+
+func (c *wsClient) expectClose(code int) {
+	c.t.Helper()
+	opcode, payload, _, _ := c.receive()
+	if opcode != wsClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		c.t.Fatalf("Expected a close frame with %d, got %d %q", code, opcode, payload)
+	}
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+func echoServer(t *testing.T, opts WebSocketOptions, closed chan<- error) *httptest.Server {
+	router := NewRouter()
+	router.WebSocket("/ws", opts, func(r *http.Request, conn *WebSocketConn) error {
+		for {
+			kind, message, err := conn.ReadMessage()
+			if err != nil {
+				if closed != nil {
+					closed <- err
+				}
+				return err
+			}
+			if err := conn.WriteMessage(kind, message); err != nil {
+				return err
+			}
+		}
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := echoServer(t, WebSocketOptions{Subprotocols: []string{"v2", "v1"}}, nil)
+	client := dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Protocol": {"v1, v2"}})
+
+	if accept := client.header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected the RFC 6455 accept key, got %q", accept)
+	}
+	if protocol := client.header.Get("Sec-WebSocket-Protocol"); protocol != "v2" {
+		t.Errorf("Expected the preferred subprotocol, got %q", protocol)
+	}
+	if client.header.Get("Sec-WebSocket-Extensions") != "" {
+		t.Error("Expected no extension without Compression")
+	}
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", WebSocketOptions{Origins: []string{"https://app.example.com"}}, func(r *http.Request, conn *WebSocketConn) error {
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected int
+	}{
+		{"plain request", map[string]string{"Upgrade": ""}, http.StatusUpgradeRequired},
+		{"version", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"key", map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{"origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://example.com/ws", nil)
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Sec-WebSocket-Version", "13")
+			r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			for name, value := range test.header {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, w.Code)
+			}
+		})
+	}
+
+	for _, origin := range []string{"https://app.example.com", "http://example.com"} {
+		r := httptest.NewRequest("GET", "http://example.com/ws", nil)
+		r.Header.Set("Origin", origin)
+		if !websocketOriginAllowed(r, &WebSocketOptions{Origins: []string{"https://app.example.com"}}) {
+			t.Errorf("Expected %s to be allowed", origin)
+		}
+	}
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	closed := make(chan error, 1)
+	server := echoServer(t, WebSocketOptions{}, closed)
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	client.send(wsText, []byte("hello"), true, false)
+	if opcode, payload, fin, _ := client.receive(); opcode != wsText || string(payload) != "hello" || !fin {
+		t.Errorf("Expected the text message back, got %d %q", opcode, payload)
+	}
+
+	large := bytes.Repeat([]byte{7}, 70000)
+	client.send(wsBinary, large, true, false)
+	if opcode, payload, _, _ := client.receive(); opcode != wsBinary || !bytes.Equal(payload, large) {
+		t.Errorf("Expected the binary message back, got %d with %d bytes", opcode, len(payload))
+	}
+
+	client.send(wsText, []byte("frag"), false, false)
+	client.send(wsPing, []byte("are you there"), true, false)
+	if opcode, payload, _, _ := client.receive(); opcode != wsPong || string(payload) != "are you there" {
+		t.Errorf("Expected a pong between fragments, got %d %q", opcode, payload)
+	}
+	client.send(wsContinuation, []byte("mented"), true, false)
+	if _, payload, _, _ := client.receive(); string(payload) != "fragmented" {
+		t.Errorf("Expected the fragments to be assembled, got %q", payload)
+	}
+
+	client.send(wsClose, closePayload(CloseGoingAway, "bye"), true, false)
+	client.expectClose(CloseGoingAway)
+
+	err := <-closed
+	if closeErr, ok := err.(*CloseError); !ok || closeErr.Code != CloseGoingAway || closeErr.Reason != "bye" {
+		t.Errorf("Expected the close of the client, got %v", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     WebSocketOptions
+		send     func(c *wsClient)
+		expected int
+	}{
+		{"too big", WebSocketOptions{MaxMessageSize: 8}, func(c *wsClient) {
+			c.send(wsText, []byte("0123"), false, false)
+			c.send(wsContinuation, []byte("45678"), true, false)
+		}, CloseMessageTooBig},
+		{"huge continuation", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsText, []byte("0123456789"), false, false)
+			frame := binary.BigEndian.AppendUint64([]byte{0x80 | wsContinuation, 0x80 | 127}, 1<<63-1)
+			c.conn.Write(append(frame, 1, 2, 3, 4))
+		}, CloseMessageTooBig},
+		{"invalid utf-8", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsText, []byte{0xff, 0xfe}, true, false)
+		}, CloseInvalidPayload},
+		{"unexpected continuation", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsContinuation, []byte("x"), true, false)
+		}, CloseProtocolError},
+		{"unnegotiated compression", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsText, []byte("x"), true, true)
+		}, CloseProtocolError},
+		{"fragmented control", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsPing, nil, false, false)
+		}, CloseProtocolError},
+		{"invalid close code", WebSocketOptions{}, func(c *wsClient) {
+			c.send(wsClose, closePayload(999, ""), true, false)
+		}, CloseProtocolError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			closed := make(chan error, 1)
+			client := dialWebSocket(t, echoServer(t, test.opts, closed), "/ws", nil)
+			test.send(client)
+			client.expectClose(test.expected)
+
+			if closeErr, ok := (<-closed).(*CloseError); !ok || closeErr.Code != test.expected {
+				t.Errorf("Expected ReadMessage to fail with %d, got %v", test.expected, closeErr)
+			}
+		})
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	server := echoServer(t, WebSocketOptions{Compression: true, FragmentSize: 16}, nil)
+	client := dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+
+	if extensions := client.header.Get("Sec-WebSocket-Extensions"); !strings.HasPrefix(extensions, "permessage-deflate") {
+		t.Fatalf("Expected permessage-deflate to be negotiated, got %q", extensions)
+	}
+
+	message := strings.Repeat("compress me ", 50)
+	var buf bytes.Buffer
+	writer, _ := flate.NewWriter(&buf, flate.BestCompression)
+	writer.Write([]byte(message))
+	writer.Flush()
+	client.send(wsText, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}), true, true)
+
+	var compressed []byte
+	opcode, payload, fin, rsv1 := client.receive()
+	if opcode != wsText || !rsv1 || fin {
+		t.Fatalf("Expected a compressed first fragment, got opcode %d rsv1 %t fin %t", opcode, rsv1, fin)
+	}
+	for compressed = payload; !fin; {
+		opcode, payload, fin, rsv1 = client.receive()
+		if opcode != wsContinuation || rsv1 || len(payload) > 16 {
+			t.Fatalf("Expected continuation fragments, got opcode %d rsv1 %t", opcode, rsv1)
+		}
+		compressed = append(compressed, payload...)
+	}
+
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(compressed), bytes.NewReader([]byte{0, 0, 0xff, 0xff, 1, 0, 0, 0xff, 0xff})))
+	decompressed, err := io.ReadAll(reader)
+	if err != nil || string(decompressed) != message {
+		t.Errorf("Expected the message back, got %q %v", decompressed, err)
+	}
+
+	for _, offer := range []string{"permessage-deflate; server_max_window_bits=10", "x-webkit-deflate-frame"} {
+		if offersDeflate(http.Header{"Sec-Websocket-Extensions": {offer}}) {
+			t.Errorf("Expected %q to be declined", offer)
+		}
+	}
+}
+
+func TestWebSocketPingAndClose(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", WebSocketOptions{PingInterval: 20 * time.Millisecond}, func(r *http.Request, conn *WebSocketConn) error {
+		time.Sleep(30 * time.Millisecond)
+		return conn.WriteMessage(TextMessage, []byte("done"))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	if opcode, _, _, _ := client.receive(); opcode != wsPing {
+		t.Fatalf("Expected a ping, got %d", opcode)
+	}
+	client.send(wsPong, nil, true, false)
+	if opcode, payload, _, _ := client.receive(); opcode != wsText || string(payload) != "done" {
+		t.Fatalf("Expected the message, got %d %q", opcode, payload)
+	}
+	client.expectClose(CloseNormal)
+	client.send(wsClose, closePayload(CloseNormal, ""), true, false)
+
+	if _, err := client.br.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the server to close the connection, got %v", err)
+	}
+}
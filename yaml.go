@@ -0,0 +1,609 @@
+package simplerouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a JSON value with the key order of its source preserved.
+type yamlNode struct {
+	// kind is 'm' for objects, 'a' for arrays and 's' for scalars.
+	kind   byte
+	keys   []string
+	values []*yamlNode
+	// scalar is the JSON encoding of a scalar value.
+	scalar string
+}
+
+// jsonToYAML converts a JSON document to the equivalent block-style YAML.
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	node, err := readYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if node.kind == 's' || len(node.values) == 0 {
+		b.WriteString(node.inline())
+		b.WriteByte('\n')
+	} else {
+		node.write(&b, 0)
+	}
+	return b.Bytes(), nil
+}
+
+func readYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		node := &yamlNode{kind: 'a'}
+		if token == '{' {
+			node.kind = 'm'
+		}
+		for decoder.More() {
+			if node.kind == 'm' {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			value, err := readYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{kind: 's', scalar: yamlString(token)}, nil
+	case nil:
+		return &yamlNode{kind: 's', scalar: "null"}, nil
+	default:
+		return &yamlNode{kind: 's', scalar: fmt.Sprint(token)}, nil
+	}
+}
+
+// inline returns the flow form of scalars and empty collections.
+func (n *yamlNode) inline() string {
+	switch {
+	case n.kind == 's':
+		return n.scalar
+	case n.kind == 'm':
+		return "{}"
+	}
+	return "[]"
+}
+
+func (n *yamlNode) isBlock() bool {
+	return n.kind != 's' && len(n.values) > 0
+}
+
+func (n *yamlNode) write(b *bytes.Buffer, indent int) {
+	prefix := strings.Repeat("  ", indent)
+
+	for i, value := range n.values {
+		if n.kind == 'm' {
+			b.WriteString(prefix + yamlString(n.keys[i]) + ":")
+			if value.isBlock() {
+				b.WriteByte('\n')
+				value.write(b, indent+1)
+				continue
+			}
+			b.WriteString(" " + value.inline() + "\n")
+			continue
+		}
+
+		b.WriteString(prefix + "-")
+		if !value.isBlock() {
+			b.WriteString(" " + value.inline() + "\n")
+			continue
+		}
+
+		// Start the first line of a nested collection after the dash.
+		var nested bytes.Buffer
+		value.write(&nested, indent+1)
+		b.WriteString(" " + strings.TrimPrefix(nested.String(), prefix+"  "))
+	}
+}
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/$][A-Za-z0-9_./$+ -]*$`)
+
+// yamlString returns s unquoted when YAML reads it back as the same string, and
+// double-quoted with JSON escapes, which YAML shares, otherwise.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+	default:
+		if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") {
+			return s
+		}
+	}
+
+	var quoted bytes.Buffer
+	encoder := json.NewEncoder(&quoted)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(quoted.String(), "\n")
+}
+
+// parseYAML decodes the subset of YAML used for API documents into the values
+// encoding/json produces: block and flow mappings and sequences, plain and
+// quoted scalars, literal and folded block scalars, and comments. Anchors,
+// aliases, tags and multiple documents are not supported.
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed in indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(raw) - len(trimmed), text: trimmed, raw: raw})
+	}
+
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	if text := p.lines[p.pos].text; text == "---" || strings.HasPrefix(text, "--- ") {
+		p.pos++
+		p.skipBlank()
+	}
+
+	value, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text != "..." {
+		return nil, p.errorf("unexpected content")
+	}
+	return value, nil
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	line := 0
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].number
+	}
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		text := stripYAMLComment(p.lines[p.pos].text)
+		if text != "" {
+			return
+		}
+		p.pos++
+	}
+}
+
+// parseBlock parses the collection or scalar starting at the current line,
+// which is indented by indent.
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	line := p.lines[p.pos]
+	text := stripYAMLComment(line.text)
+
+	if text == "-" || strings.HasPrefix(text, "- ") {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(text); ok {
+		return p.parseMapping(indent)
+	}
+
+	p.pos++
+	return parseYAMLScalar(text)
+}
+
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return items, nil
+		}
+
+		line := p.lines[p.pos]
+		text := stripYAMLComment(line.text)
+		if line.indent != indent || (text != "-" && !strings.HasPrefix(text, "- ")) {
+			if line.indent > indent {
+				return nil, p.errorf("bad indentation of a sequence entry")
+			}
+			return items, nil
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		// The entry continues on the same line, as if indented past the dash.
+		offset := line.indent + len(line.text) - len(strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " "))
+		p.lines[p.pos] = yamlLine{number: line.number, indent: offset, text: strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " "), raw: line.raw}
+		item, err := p.parseBlock(offset)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	mapping := map[string]any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return mapping, nil
+		}
+
+		line := p.lines[p.pos]
+		if line.indent != indent {
+			if line.indent > indent {
+				return nil, p.errorf("bad indentation of a mapping entry")
+			}
+			return mapping, nil
+		}
+
+		text := stripYAMLComment(line.text)
+		key, rest, ok := splitYAMLKey(text)
+		if !ok {
+			return mapping, nil
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+
+		var value any
+		var err error
+		switch {
+		case rest == "":
+			value, err = p.parseNested(indent)
+		case rest == "|" || rest == ">" || strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			value, err = p.parseBlockScalar(indent, rest)
+		default:
+			value, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+}
+
+// parseNested parses the value of a key or sequence entry on the lines after
+// it. Sequences may be indented as much as their key.
+func (p *yamlParser) parseNested(parent int) (any, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+
+	line := p.lines[p.pos]
+	text := stripYAMLComment(line.text)
+	isSequence := text == "-" || strings.HasPrefix(text, "- ")
+	if line.indent > parent || (line.indent == parent && isSequence) {
+		return p.parseBlock(line.indent)
+	}
+	return nil, nil
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar.
+func (p *yamlParser) parseBlockScalar(parent int, header string) (any, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+
+	var lines []string
+	indent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if line.indent <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = line.indent
+		}
+		if line.indent < indent {
+			break
+		}
+		lines = append(lines, line.raw[indent:])
+		p.pos++
+	}
+
+	// Trailing blank lines belong to the chomping, not the content.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			switch {
+			case !folded || line == "" || lines[i-1] == "" || strings.HasPrefix(line, " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+
+	text := b.String()
+	switch {
+	case strings.HasPrefix(chomp, "-"):
+	case strings.HasPrefix(chomp, "+"):
+		text += "\n" + strings.Repeat("\n", trailing)
+	case len(lines) > 0:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// splitYAMLKey splits a "key: value" line.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+
+	if text[0] == '"' || text[0] == '\'' {
+		end := quotedYAMLEnd(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		unquoted, err := parseYAMLScalar(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		return unquoted.(string), strings.TrimSpace(text[end+2:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// quotedYAMLEnd returns the index of the quote closing the string text starts
+// with, or -1.
+func quotedYAMLEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment outside of quotes.
+func stripYAMLComment(text string) string {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '"' || text[i] == '\'':
+			if end := quotedYAMLEnd(text[i:]); end >= 0 {
+				i += end
+			}
+		case text[i] == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+	return strings.TrimRight(text, " ")
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// parseYAMLScalar parses a flow value: a scalar, or a flow collection.
+func parseYAMLScalar(text string) (any, error) {
+	flow := &yamlFlow{text: text}
+	value, err := flow.value()
+	if err != nil {
+		return nil, err
+	}
+	if flow.skipSpace(); flow.pos != len(flow.text) {
+		return nil, fmt.Errorf("yaml: unexpected %q after value", flow.text[flow.pos:])
+	}
+	return value, nil
+}
+
+type yamlFlow struct {
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.skipSpace()
+	if f.pos == len(f.text) {
+		return nil, nil
+	}
+
+	switch f.text[f.pos] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		end := quotedYAMLEnd(f.text[f.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("yaml: unterminated string %s", f.text[f.pos:])
+		}
+		quoted := f.text[f.pos : f.pos+end+1]
+		f.pos += end + 1
+		if quoted[0] == '\'' {
+			return strings.ReplaceAll(quoted[1:len(quoted)-1], "''", "'"), nil
+		}
+		var s string
+		if err := json.Unmarshal([]byte(quoted), &s); err != nil {
+			return nil, fmt.Errorf("yaml: invalid string %s: %w", quoted, err)
+		}
+		return s, nil
+	case '&', '*', '!':
+		return nil, fmt.Errorf("yaml: anchors, aliases and tags are not supported")
+	}
+
+	return resolveYAMLPlain(f.plain()), nil
+}
+
+// plain reads a plain scalar, which ends at a flow indicator inside a flow
+// collection.
+func (f *yamlFlow) plain() string {
+	start := f.pos
+	inFlow := strings.ContainsAny(f.text[:start], "[{")
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if inFlow && c == ':' && (f.pos+1 == len(f.text) || f.text[f.pos+1] == ' ') {
+			break
+		}
+		f.pos++
+	}
+	return strings.TrimSpace(f.text[start:f.pos])
+}
+
+func (f *yamlFlow) sequence() (any, error) {
+	f.pos++
+	items := []any{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == ']' {
+			f.pos++
+			return items, nil
+		}
+
+		item, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *yamlFlow) mapping() (any, error) {
+	f.pos++
+	mapping := map[string]any{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == '}' {
+			f.pos++
+			return mapping, nil
+		}
+
+		key, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos == len(f.text) || f.text[f.pos] != ':' {
+			return nil, fmt.Errorf("yaml: expected ':' in flow mapping %s", f.text)
+		}
+		f.pos++
+
+		value, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		mapping[fmt.Sprint(key)] = value
+
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma between flow entries, leaving the closing
+// bracket in place.
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.pos == len(f.text):
+		return fmt.Errorf("yaml: unterminated flow collection %s", f.text)
+	case f.text[f.pos] == ',':
+		f.pos++
+	case f.text[f.pos] != closing:
+		return fmt.Errorf("yaml: unexpected %q in flow collection", f.text[f.pos])
+	}
+	return nil
+}
+
+// resolveYAMLPlain applies the YAML core schema to a plain scalar.
+func resolveYAMLPlain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlInt.MatchString(s) {
+		return json.Number(strings.TrimPrefix(s, "+"))
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}